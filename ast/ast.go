@@ -2,6 +2,8 @@ package ast
 
 import (
 	"bytes"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/BentleyOph/monke/token"
@@ -9,7 +11,27 @@ import (
 
 type Node interface {
 	TokenLiteral() string 
-	String () string
+	String () string // Monke source that parses back into the same tree, with every operator parenthesized
+	Pos() int // byte offset of the first character belonging to the node
+	End() int // byte offset immediately after the node
+}
+
+// isNil reports whether node is nil or holds a nil pointer
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// nodeString returns node.String(), or "" when node is nil or holds a nil pointer,
+// so that printing a partially built tree never panics
+func nodeString(node Node) string {
+	if isNil(node) {
+		return ""
+	}
+	return node.String()
 }
 
 type Statement interface {
@@ -35,18 +57,33 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 func (p *Program) String() string {
+	return joinStatements(p.Statements)
+}
+
+// joinStatements prints statements as source that parses back into the same statements.
+// Only expression statements print without a semicolon, so one is added after them
+// when another statement follows; otherwise a( or an infix operator in the next
+// statement would continue the expression.
+func joinStatements(statements []Statement) string {
 	var out bytes.Buffer // bytes.Buffer is a buffer of bytes with a Read and Write method
-	for _, s := range p.Statements{ // iterate over each statement in the program
-		out.WriteString(s.String()) // write the string representation of the statement to the buffer
+	for i, s := range statements{ // iterate over each statement
+		out.WriteString(nodeString(s)) // write the string representation of the statement to the buffer
+		if _, ok := s.(*ExpressionStatement); ok && i < len(statements)-1 {
+			out.WriteString(";")
+		}
 	}
 	return out.String() // return the buffer as a string
 }
 
 
+// LetStatement binds a name, let x = 1, or destructures a value into the names of
+// a pattern, let [a, ...rest] = xs. Exactly one of Name and Pattern is set.
 type LetStatement struct {
-	Token token.Token // token.LET
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // token.LET
+	Name    *Identifier
+	Pattern Pattern // an *ArrayPattern or *HashPattern when destructuring
+	Value   Expression
+	Doc     string // the /// doc comments before the statement, without the slashes
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -56,15 +93,30 @@ func (ls *LetStatement) TokenLiteral() string {
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(nodeString(ls.Pattern))
+	} else {
+		out.WriteString(nodeString(ls.Name))
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
-		out.WriteString(ls.Value.String())
+		out.WriteString(nodeString(ls.Value))
 	}
 	out.WriteString(";")
 	return out.String()
 }
 
+// Names returns the names the statement binds, in source order
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return Bindings(ls.Pattern)
+	}
+	if ls.Name == nil {
+		return []*Identifier{}
+	}
+	return []*Identifier{ls.Name}
+}
+
 
 
 type Identifier struct {
@@ -94,7 +146,7 @@ func (rs *ReturnStatement) String()string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
 	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
+		out.WriteString(nodeString(rs.ReturnValue))
 	}
 	out.WriteString(";")
 	return out.String()
@@ -114,7 +166,7 @@ func(es *ExpressionStatement)TokenLiteral()string{
 }
 func(es *ExpressionStatement)String() string {
 	if es.Expression != nil {
-		return es.Expression.String()
+		return nodeString(es.Expression)
 	}
 	return ""
 }
@@ -143,7 +195,7 @@ func (sl *StringLiteral) TokenLiteral() string{
 	return sl.Token.Literal
 }
 func (sl *StringLiteral) String() string {
-	return sl.Token.Literal
+	return "\"" + sl.Value + "\""
 }
 
 
@@ -167,7 +219,7 @@ func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
-	out.WriteString(pe.Right.String())
+	out.WriteString(nodeString(pe.Right))
 	out.WriteString(")")
 
 	return out.String()
@@ -191,9 +243,9 @@ func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
-	out.WriteString(oe.Left.String()) // String() will recursively call the String() method of the left expression
+	out.WriteString(nodeString(oe.Left)) // String() will recursively call the String() method of the left expression
 	out.WriteString(" " + oe.Operator + " ") // print the operator and a space
-	out.WriteString(oe.Right.String()) // recursively call the String() method of the right expression
+	out.WriteString(nodeString(oe.Right)) // recursively call the String() method of the right expression
 	out.WriteString(")")
 	return out.String()
 }
@@ -212,6 +264,19 @@ func (b *Boolean) String() string {
 	return b.Token.Literal
 }
 
+// NullLiteral is the absent value: null
+type NullLiteral struct {
+	Token token.Token // The 'null' token
+}
+
+func (nl *NullLiteral) expressionNode() {}
+func (nl *NullLiteral) TokenLiteral() string {
+	return nl.Token.Literal
+}
+func (nl *NullLiteral) String() string {
+	return "null"
+}
+
 
 type IfExpression struct {
 	Token token.Token // The 'if' token
@@ -226,22 +291,23 @@ func(ie *IfExpression)TokenLiteral()string{
 }
 func (ie *IfExpression)String() string{
 	var out bytes.Buffer
-	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
-	out.WriteString(" ")
-	out.WriteString(ie.Consequence.String())
+	out.WriteString("if (")
+	out.WriteString(nodeString(ie.Condition))
+	out.WriteString(") ")
+	out.WriteString(nodeString(ie.Consequence))
 
 	if ie.Alternative != nil {
-		out.WriteString("else")
-		out.WriteString(ie.Alternative.String())
+		out.WriteString(" else ")
+		out.WriteString(nodeString(ie.Alternative))
 	}
 	return out.String()
 }
 
 
 type BlockStatement struct {
-	Token token.Token
+	Token token.Token // The '{' token
 	Statements []Statement
+	Rbrace token.Token // The '}' token
 }
 
 func (bs *BlockStatement) statementNode(){}
@@ -249,18 +315,20 @@ func (bs *BlockStatement) TokenLiteral() string{
 	return bs.Token.Literal
 }
 func (bs *BlockStatement) String() string{
-	var out bytes.Buffer
-	for _,s := range bs.Statements{
-		out.WriteString(s.String())
+	if len(bs.Statements) == 0 {
+		return "{ }"
 	}
-	return out.String()
+	return "{ " + joinStatements(bs.Statements) + " }"
 }
 
 
 
 type FunctionLiteral struct {
 	Token token.Token // The 'fn' token
+	Name string // the declared or let-bound name, "" for an anonymous function
 	Parameters []*Identifier
+	Defaults []Expression // default value of each parameter, nil where it has none; nil when no parameter has one
+	Rest *Identifier // the variadic ...rest parameter, or nil
 	Body *BlockStatement
 }
 func(fl *FunctionLiteral) expressionNode(){}
@@ -270,22 +338,135 @@ func(fl *FunctionLiteral) TokenLiteral() string{
 func(fl *FunctionLiteral) String() string{
 	var out bytes.Buffer
 	params := []string{}
-	for _,p := range fl.Parameters{
-		params = append(params, p.String())
+	for i,p := range fl.Parameters{
+		param := nodeString(p)
+		if value := fl.Default(i); value != nil {
+			param += " = " + nodeString(value)
+		}
+		params = append(params, param)
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+nodeString(fl.Rest))
 	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
-	out.WriteString(fl.Body.String())
+	out.WriteString(nodeString(fl.Body))
+	return out.String()
+}
+
+// Default returns the default value of the i-th parameter, or nil when it has none
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i >= len(fl.Defaults) {
+		return nil
+	}
+	return fl.Defaults[i]
+}
+
+// Arity returns the smallest and largest number of arguments the function accepts.
+// max is -1 when a variadic parameter takes any number of extra arguments.
+func (fl *FunctionLiteral) Arity() (min, max int) {
+	for i := range fl.Parameters {
+		if fl.Default(i) == nil {
+			min++
+		}
+	}
+	max = len(fl.Parameters)
+	if fl.Rest != nil {
+		max = -1
+	}
+	return min, max
+}
+
+// MacroLiteral is macro(<parameters>) { <body> }. A macro bound with let is not a
+// value: its calls are replaced by the syntax it quotes before the program runs, see
+// package macro.
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode() {}
+func (ml *MacroLiteral) TokenLiteral() string {
+	return ml.Token.Literal
+}
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, nodeString(p))
+	}
+	return ml.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + nodeString(ml.Body)
+}
+
+// FunctionStatement declares a named function: fn name(params) { ... }.
+// Declarations are hoisted to the top of their program or block, see HoistedFunctions.
+type FunctionStatement struct {
+	Token    token.Token // The 'fn' token
+	Name     *Identifier
+	Function *FunctionLiteral
+	Doc      string // the /// doc comments before the declaration, without the slashes
+}
+
+func (fs *FunctionStatement) statementNode() {}
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(fs.TokenLiteral() + " ")
+	out.WriteString(nodeString(fs.Name))
+	out.WriteString(strings.TrimPrefix(nodeString(fs.Function), fs.TokenLiteral()))
 	return out.String()
 }
 
+// HoistedFunctions returns the function declarations among statements, in source order.
+// An evaluator binds them before running the first of the statements, so that a function
+// can call the ones declared after it in the same program or block. This is what makes
+// mutual recursion work without ordering tricks.
+func HoistedFunctions(statements []Statement) []*FunctionStatement {
+	functions := []*FunctionStatement{}
+	for _, s := range statements {
+		if fs, ok := s.(*FunctionStatement); ok {
+			functions = append(functions, fs)
+		}
+	}
+	return functions
+}
+
+// StructStatement declares a record type with named fields: struct Point { x, y }.
+// It binds the name to a constructor that takes one argument per field, in order,
+// and returns a record whose fields are read with p.x and updated with p.x = 3.
+type StructStatement struct {
+	Token  token.Token // The 'struct' token
+	Name   *Identifier
+	Fields []*Identifier
+	Rbrace token.Token // The '}' token
+	Doc    string      // the /// doc comments before the declaration, without the slashes
+}
+
+func (ss *StructStatement) statementNode() {}
+func (ss *StructStatement) TokenLiteral() string {
+	return ss.Token.Literal
+}
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, nodeString(f))
+	}
+	if len(fields) == 0 {
+		return ss.TokenLiteral() + " " + nodeString(ss.Name) + " { }"
+	}
+	return ss.TokenLiteral() + " " + nodeString(ss.Name) + " { " + strings.Join(fields, ", ") + " }"
+}
+
 
 type CallExpression struct {
 	Token token.Token // The '(' token
 	Function Expression // Identifier or FunctionLiteral
 	Arguments [] Expression
+	Rparen token.Token // The ')' token
 }
 
 func (ce *CallExpression) expressionNode(){}
@@ -296,12 +477,277 @@ func(ce *CallExpression) String() string{
 	var out bytes.Buffer
 	args := []string{}
 	for _,a := range ce.Arguments{
-		args = append(args, a.String())
+		args = append(args, nodeString(a))
 	}
-	out.WriteString(ce.Function.String())
+	out.WriteString(nodeString(ce.Function))
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
 
 	return out.String()
-}
\ No newline at end of file
+}
+
+// InfixStatement declares a user-defined binary operator, e.g. infix 6 left <+> = fn(a, b) { ... };
+type InfixStatement struct {
+	Token         token.Token // The 'infix' token
+	Precedence    int         // binding power as declared, 2 (like ==) through 6 (tighter than *)
+	Associativity string      // "left" or "right"
+	Operator      string      // the declared symbol, e.g. "<+>"
+	Function      Expression  // the function the operator is bound to
+}
+
+func (is *InfixStatement) statementNode() {}
+func (is *InfixStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+func (is *InfixStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString(strconv.Itoa(is.Precedence) + " ")
+	out.WriteString(is.Associativity + " ")
+	out.WriteString(is.Operator + " = ")
+	if is.Function != nil {
+		out.WriteString(nodeString(is.Function))
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+
+// WhileStatement runs Body for as long as Condition is truthy
+type WhileStatement struct {
+	Token     token.Token // The 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while ")
+	out.WriteString("(" + nodeString(ws.Condition) + ") ")
+	out.WriteString(nodeString(ws.Body))
+	return out.String()
+}
+
+
+// ForStatement is a C-style loop: for (Init; Condition; Post) { Body }.
+// Each of Init, Condition and Post may be nil when left out in the source.
+type ForStatement struct {
+	Token     token.Token // The 'for' token
+	Init      Statement   // a let or expression statement
+	Condition Expression
+	Post      Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(nodeString(fs.Init), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(nodeString(fs.Condition))
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(nodeString(fs.Post))
+	}
+	out.WriteString(") ")
+	out.WriteString(nodeString(fs.Body))
+	return out.String()
+}
+
+
+// BreakStatement leaves the innermost enclosing loop
+type BreakStatement struct {
+	Token token.Token // The 'break' token
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+
+// ContinueStatement skips to the next iteration of the innermost enclosing loop
+type ContinueStatement struct {
+	Token token.Token // The 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
+
+// AssignExpression rebinds an existing name or updates an index or a field: x = 1,
+// x += 1, arr[0] = 1, p.x = 1
+type AssignExpression struct {
+	Token    token.Token // The assignment operator token, e.g. '=' or '+='
+	Target   Expression  // *Identifier, *IndexExpression or *MemberExpression, possibly parenthesized
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(nodeString(ae.Target))
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(nodeString(ae.Value))
+	out.WriteString(")")
+	return out.String()
+}
+
+
+type IndexExpression struct {
+	Token    token.Token // The '[' token, or '?[' when Optional
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // The ']' token
+	Optional bool        // a?[i], which is null instead of an error when a is null
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(nodeString(ie.Left))
+	if ie.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	out.WriteString(nodeString(ie.Index))
+	out.WriteString("])")
+	return out.String()
+}
+
+// MemberExpression reads a named member of a value, such as a field of a record: a.b, a?.b
+type MemberExpression struct {
+	Token    token.Token // The '.' token, or '?.' when Optional
+	Object   Expression
+	Property *Identifier
+	Optional bool // a?.b, which is null instead of an error when a is null
+}
+
+func (me *MemberExpression) expressionNode() {}
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(nodeString(me.Object))
+	if me.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString(".")
+	out.WriteString(nodeString(me.Property))
+	out.WriteString(")")
+	return out.String()
+}
+
+
+// MatchExpression compares a value against the patterns of its arms in order, and
+// evaluates to the body of the first arm that matches and whose guard holds:
+// match (x) { 0 => "zero", n if n > 0 => "positive", _ => "negative" }
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Token // The '}' token
+}
+
+func (me *MatchExpression) expressionNode() {}
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("match (")
+	out.WriteString(nodeString(me.Subject))
+	out.WriteString(") {")
+	for i, arm := range me.Arms {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString(" " + nodeString(arm))
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// MatchArm is one case of a match expression: <pattern> [if <guard>] => <body>
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression  // nil without one
+	Token   token.Token // The '=>' token
+	Body    Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string {
+	return ma.Token.Literal
+}
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(nodeString(ma.Pattern))
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(nodeString(ma.Guard))
+	}
+	out.WriteString(" => ")
+	out.WriteString(nodeString(ma.Body))
+	return out.String()
+}
+
+
+// ParenExpression is an expression in parentheses. The parentheses only group the
+// expression, so it prints as the expression itself; they are kept in the tree for
+// the source range they cover.
+type ParenExpression struct {
+	Token      token.Token // The '(' token
+	Expression Expression
+	Rparen     token.Token // The ')' token
+}
+
+func (pe *ParenExpression) expressionNode() {}
+func (pe *ParenExpression) TokenLiteral() string {
+	return pe.Token.Literal
+}
+func (pe *ParenExpression) String() string {
+	return nodeString(pe.Expression)
+}
+
+// Unparen returns the expression with any enclosing parentheses removed
+func Unparen(e Expression) Expression {
+	for {
+		paren, ok := e.(*ParenExpression)
+		if !ok || paren == nil {
+			return e
+		}
+		e = paren.Expression
+	}
+}
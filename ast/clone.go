@@ -0,0 +1,185 @@
+package ast
+
+import "reflect"
+
+// Clone returns a deep copy of the tree rooted at node, positions included, so that
+// the copy can be modified without affecting the original. Missing children stay
+// missing in the copy.
+//
+// Nodes of types added by parser extensions are copied shallowly: the copy holds a
+// new node of the same type whose fields are those of the original.
+func Clone(node Node) Node {
+	if isNil(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(n.Statements)}
+
+	// Statements
+	case *LetStatement:
+		return &LetStatement{Token: n.Token, Name: clone(n.Name), Pattern: clone(n.Pattern), Value: clone(n.Value), Doc: n.Doc}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: n.Token, ReturnValue: clone(n.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: n.Token, Expression: clone(n.Expression)}
+	case *BlockStatement:
+		return &BlockStatement{Token: n.Token, Statements: cloneStatements(n.Statements), Rbrace: n.Rbrace}
+	case *FunctionStatement:
+		return &FunctionStatement{Token: n.Token, Name: clone(n.Name), Function: clone(n.Function), Doc: n.Doc}
+	case *InfixStatement:
+		c := *n
+		c.Function = clone(n.Function)
+		return &c
+	case *WhileStatement:
+		return &WhileStatement{Token: n.Token, Condition: clone(n.Condition), Body: clone(n.Body)}
+	case *ForStatement:
+		return &ForStatement{Token: n.Token, Init: clone(n.Init), Condition: clone(n.Condition),
+			Post: clone(n.Post), Body: clone(n.Body)}
+	case *StructStatement:
+		c := &StructStatement{Token: n.Token, Name: clone(n.Name), Rbrace: n.Rbrace, Doc: n.Doc}
+		if n.Fields != nil {
+			c.Fields = make([]*Identifier, len(n.Fields))
+			for i, f := range n.Fields {
+				c.Fields[i] = clone(f)
+			}
+		}
+		return c
+	case *BreakStatement:
+		return &BreakStatement{Token: n.Token}
+	case *ContinueStatement:
+		return &ContinueStatement{Token: n.Token}
+
+	// Expressions
+	case *Identifier:
+		c := *n
+		return &c
+	case *IntegerLiteral:
+		c := *n
+		return &c
+	case *StringLiteral:
+		c := *n
+		return &c
+	case *Boolean:
+		c := *n
+		return &c
+	case *NullLiteral:
+		return &NullLiteral{Token: n.Token}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: n.Token, Operator: n.Operator, Right: clone(n.Right)}
+	case *InfixExpression:
+		return &InfixExpression{Token: n.Token, Left: clone(n.Left), Operator: n.Operator, Right: clone(n.Right)}
+	case *IfExpression:
+		return &IfExpression{Token: n.Token, Condition: clone(n.Condition),
+			Consequence: clone(n.Consequence), Alternative: clone(n.Alternative)}
+	case *FunctionLiteral:
+		c := &FunctionLiteral{Token: n.Token, Name: n.Name, Rest: clone(n.Rest), Body: clone(n.Body)}
+		if n.Parameters != nil {
+			c.Parameters = make([]*Identifier, len(n.Parameters))
+			for i, param := range n.Parameters {
+				c.Parameters[i] = clone(param)
+			}
+		}
+		if n.Defaults != nil {
+			c.Defaults = make([]Expression, len(n.Defaults))
+			for i, value := range n.Defaults {
+				if value != nil {
+					c.Defaults[i] = clone(value)
+				}
+			}
+		}
+		return c
+	case *MacroLiteral:
+		c := &MacroLiteral{Token: n.Token, Body: clone(n.Body)}
+		if n.Parameters != nil {
+			c.Parameters = make([]*Identifier, len(n.Parameters))
+			for i, param := range n.Parameters {
+				c.Parameters[i] = clone(param)
+			}
+		}
+		return c
+	case *CallExpression:
+		c := &CallExpression{Token: n.Token, Function: clone(n.Function), Rparen: n.Rparen}
+		if n.Arguments != nil {
+			c.Arguments = make([]Expression, len(n.Arguments))
+			for i, arg := range n.Arguments {
+				c.Arguments[i] = clone(arg)
+			}
+		}
+		return c
+	case *AssignExpression:
+		return &AssignExpression{Token: n.Token, Target: clone(n.Target), Operator: n.Operator, Value: clone(n.Value)}
+	case *IndexExpression:
+		return &IndexExpression{Token: n.Token, Left: clone(n.Left), Index: clone(n.Index), Rbracket: n.Rbracket,
+			Optional: n.Optional}
+	case *MemberExpression:
+		return &MemberExpression{Token: n.Token, Object: clone(n.Object), Property: clone(n.Property), Optional: n.Optional}
+	case *ParenExpression:
+		return &ParenExpression{Token: n.Token, Expression: clone(n.Expression), Rparen: n.Rparen}
+	case *MatchExpression:
+		c := &MatchExpression{Token: n.Token, Subject: clone(n.Subject), Rbrace: n.Rbrace}
+		if n.Arms != nil {
+			c.Arms = make([]*MatchArm, len(n.Arms))
+			for i, arm := range n.Arms {
+				c.Arms[i] = clone(arm)
+			}
+		}
+		return c
+	case *MatchArm:
+		return &MatchArm{Pattern: clone(n.Pattern), Guard: clone(n.Guard), Token: n.Token, Body: clone(n.Body)}
+
+	// Patterns
+	case *WildcardPattern:
+		return &WildcardPattern{Token: n.Token}
+	case *BindingPattern:
+		return &BindingPattern{Name: clone(n.Name)}
+	case *LiteralPattern:
+		return &LiteralPattern{Value: clone(n.Value)}
+	case *ArrayPattern:
+		c := &ArrayPattern{Token: n.Token, Rest: clone(n.Rest), Rbracket: n.Rbracket}
+		if n.Elements != nil {
+			c.Elements = make([]Pattern, len(n.Elements))
+			for i, e := range n.Elements {
+				c.Elements[i] = clone(e)
+			}
+		}
+		return c
+	case *HashPattern:
+		c := &HashPattern{Token: n.Token, Rbrace: n.Rbrace}
+		if n.Pairs != nil {
+			c.Pairs = make([]*HashPatternPair, len(n.Pairs))
+			for i, pair := range n.Pairs {
+				c.Pairs[i] = &HashPatternPair{Key: clone(pair.Key), Value: clone(pair.Value)}
+			}
+		}
+		return c
+	}
+
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr {
+		return node
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(Node)
+}
+
+func cloneStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	c := make([]Statement, len(statements))
+	for i, s := range statements {
+		c[i] = clone(s)
+	}
+	return c
+}
+
+// clone copies node, keeping its static type
+func clone[T Node](node T) T {
+	if isNil(node) {
+		return node
+	}
+	return Clone(node).(T)
+}
@@ -0,0 +1,75 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/token"
+)
+
+func TestClone(t *testing.T) {
+	inputs := []string{
+		"let x = -1 + 2 * (3 - a[0]);",
+		"return f(1, \"s\", true);",
+		"if (a) { b } else { c }",
+		"fn f(a, b = 2, ...c) { a; return b; }",
+		"let g = fn() {}; g()",
+		"while (x) { x -= 1; continue; }",
+		"for (let i = 0; i < n; i += 1) { break; }",
+		"for (;;) {}",
+		"infix 5 right <+> = fn(a, b) { a }; 1 <+> 2",
+		"let m = macro(a) { quote(unquote(a) + 1) };",
+		"cfg?.db?[0] ?? null",
+		`match (x) { [a, ...r] if a => r, {"k": _} => -1 }`,
+		"let [a, ...r] = xs; let {name, age: years} = person;",
+		"/// A point.\nstruct Point { x, y } p.x = p.y",
+	}
+
+	for _, input := range inputs {
+		program := parse(t, input)
+		clone := ast.Clone(program)
+		if !ast.Equal(program, clone) {
+			t.Errorf("clone of %q is not equal to it. got = %q", input, clone.String())
+		}
+
+		// the copy shares no nodes with the original, and keeps the positions
+		original := map[ast.Node]bool{}
+		var spans [][2]int
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node != nil {
+				original[node] = true
+				spans = append(spans, [2]int{node.Pos(), node.End()})
+			}
+			return true
+		})
+		i := 0
+		ast.Inspect(clone, func(node ast.Node) bool {
+			if node == nil {
+				return true
+			}
+			if original[node] {
+				t.Errorf("clone of %q shares a %T with the original", input, node)
+			}
+			if span := [2]int{node.Pos(), node.End()}; i < len(spans) && span != spans[i] {
+				t.Errorf("clone of %q moved a %T from %v to %v", input, node, spans[i], span)
+			}
+			i++
+			return true
+		})
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	program := parse(t, "let f = fn(a, b = 1) { a + b };")
+	clone := ast.Clone(program).(*ast.Program)
+
+	function := clone.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	function.Parameters[0].Value = "x"
+	function.Defaults[1] = &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}
+	function.Body.Statements = nil
+
+	expected := "let f = fn(a, b = 1) { (a + b) };"
+	if program.String() != expected {
+		t.Errorf("changing the clone changed the original. want = %q, got = %q", expected, program.String())
+	}
+}
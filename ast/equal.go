@@ -0,0 +1,208 @@
+package ast
+
+import "reflect"
+
+// Equal reports whether a and b are the same tree: nodes of the same types, with the
+// same names, operators and literal values, and equal children in the same places.
+// Positions and doc comments are ignored, and so are parentheses, whose grouping is
+// already part of the shape of the tree: "(a + b) * c" and "((a + b)) * (c)" are equal.
+//
+// Nodes of types added by parser extensions are equal when they have the same type
+// and String.
+func Equal(a, b Node) bool {
+	if ea, ok := a.(Expression); ok {
+		a = Unparen(ea)
+	}
+	if eb, ok := b.(Expression); ok {
+		b = Unparen(eb)
+	}
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+
+	switch a := a.(type) {
+	case *Program:
+		b, ok := b.(*Program)
+		return ok && equalStatements(a.Statements, b.Statements)
+
+	// Statements
+	case *LetStatement:
+		b, ok := b.(*LetStatement)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Pattern, b.Pattern) && Equal(a.Value, b.Value)
+	case *ReturnStatement:
+		b, ok := b.(*ReturnStatement)
+		return ok && Equal(a.ReturnValue, b.ReturnValue)
+	case *ExpressionStatement:
+		b, ok := b.(*ExpressionStatement)
+		return ok && Equal(a.Expression, b.Expression)
+	case *BlockStatement:
+		b, ok := b.(*BlockStatement)
+		return ok && equalStatements(a.Statements, b.Statements)
+	case *FunctionStatement:
+		b, ok := b.(*FunctionStatement)
+		return ok && Equal(a.Name, b.Name) && Equal(a.Function, b.Function)
+	case *InfixStatement:
+		b, ok := b.(*InfixStatement)
+		return ok && a.Precedence == b.Precedence && a.Associativity == b.Associativity &&
+			a.Operator == b.Operator && Equal(a.Function, b.Function)
+	case *WhileStatement:
+		b, ok := b.(*WhileStatement)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Body, b.Body)
+	case *ForStatement:
+		b, ok := b.(*ForStatement)
+		return ok && Equal(a.Init, b.Init) && Equal(a.Condition, b.Condition) &&
+			Equal(a.Post, b.Post) && Equal(a.Body, b.Body)
+	case *StructStatement:
+		b, ok := b.(*StructStatement)
+		if !ok || len(a.Fields) != len(b.Fields) || !Equal(a.Name, b.Name) {
+			return false
+		}
+		for i, f := range a.Fields {
+			if !Equal(f, b.Fields[i]) {
+				return false
+			}
+		}
+		return true
+	case *BreakStatement:
+		_, ok := b.(*BreakStatement)
+		return ok
+	case *ContinueStatement:
+		_, ok := b.(*ContinueStatement)
+		return ok
+
+	// Expressions
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *NullLiteral:
+		_, ok := b.(*NullLiteral)
+		return ok
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Right, b.Right)
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Left, b.Left) && Equal(a.Right, b.Right)
+	case *IfExpression:
+		b, ok := b.(*IfExpression)
+		return ok && Equal(a.Condition, b.Condition) && Equal(a.Consequence, b.Consequence) &&
+			Equal(a.Alternative, b.Alternative)
+	case *FunctionLiteral:
+		b, ok := b.(*FunctionLiteral)
+		if !ok || a.Name != b.Name || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i, param := range a.Parameters {
+			if !Equal(param, b.Parameters[i]) || !Equal(a.Default(i), b.Default(i)) {
+				return false
+			}
+		}
+		return Equal(a.Rest, b.Rest) && Equal(a.Body, b.Body)
+	case *MacroLiteral:
+		b, ok := b.(*MacroLiteral)
+		if !ok || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i, param := range a.Parameters {
+			if !Equal(param, b.Parameters[i]) {
+				return false
+			}
+		}
+		return Equal(a.Body, b.Body)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		if !ok || len(a.Arguments) != len(b.Arguments) || !Equal(a.Function, b.Function) {
+			return false
+		}
+		for i, arg := range a.Arguments {
+			if !Equal(arg, b.Arguments[i]) {
+				return false
+			}
+		}
+		return true
+	case *AssignExpression:
+		b, ok := b.(*AssignExpression)
+		return ok && a.Operator == b.Operator && Equal(a.Target, b.Target) && Equal(a.Value, b.Value)
+	case *IndexExpression:
+		b, ok := b.(*IndexExpression)
+		return ok && a.Optional == b.Optional && Equal(a.Left, b.Left) && Equal(a.Index, b.Index)
+	case *MemberExpression:
+		b, ok := b.(*MemberExpression)
+		return ok && a.Optional == b.Optional && Equal(a.Object, b.Object) && Equal(a.Property, b.Property)
+	case *MatchExpression:
+		b, ok := b.(*MatchExpression)
+		if !ok || len(a.Arms) != len(b.Arms) || !Equal(a.Subject, b.Subject) {
+			return false
+		}
+		for i, arm := range a.Arms {
+			if !Equal(arm, b.Arms[i]) {
+				return false
+			}
+		}
+		return true
+	case *MatchArm:
+		b, ok := b.(*MatchArm)
+		return ok && Equal(a.Pattern, b.Pattern) && Equal(a.Guard, b.Guard) && Equal(a.Body, b.Body)
+
+	// Patterns
+	case *WildcardPattern:
+		_, ok := b.(*WildcardPattern)
+		return ok
+	case *BindingPattern:
+		b, ok := b.(*BindingPattern)
+		return ok && Equal(a.Name, b.Name)
+	case *LiteralPattern:
+		b, ok := b.(*LiteralPattern)
+		return ok && Equal(a.Value, b.Value)
+	case *ArrayPattern:
+		b, ok := b.(*ArrayPattern)
+		if !ok || len(a.Elements) != len(b.Elements) || !Equal(a.Rest, b.Rest) {
+			return false
+		}
+		for i, e := range a.Elements {
+			if !Equal(e, b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *HashPattern:
+		b, ok := b.(*HashPattern)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for i, pair := range a.Pairs {
+			if !Equal(pair.Key, b.Pairs[i].Key) || !Equal(pair.Value, b.Pairs[i].Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return sameType(a, b) && a.String() == b.String()
+}
+
+func equalStatements(a, b []Statement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameType reports whether a and b hold values of the same dynamic type
+func sameType(a, b Node) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
@@ -0,0 +1,71 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/token"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"let x = 1 + 2;", "let   x=1+2", true},
+		{"(a + b) * c", "((a + b)) * (c)", true},
+		{"a + b * c", "(a + b) * c", false},
+		{"a + b", "a - b", false},
+		{"a + b", "a + c", false},
+		{"007", "7", true},
+		{`"a"`, "a", false},
+		{"f(1, 2)", "f(1)", false},
+		{"x = 1", "x += 1", false},
+		{"a[0]", "a[1]", false},
+		{"if (a) { b }", "if (a) { b } else { c }", false},
+		{"if (a) { b; c }", "if (a) { b\n c }", true},
+		{"fn(a, b = 1, ...c) { a }", "fn(a, b = 1, ...c) { a }", true},
+		{"fn(a, b = 1) { a }", "fn(a, b = 2) { a }", false},
+		{"fn(a, ...b) { a }", "fn(a) { a }", false},
+		{"let f = fn() {};", "let g = fn() {};", false},
+		{"fn f() {}", "let f = fn() {};", false},
+		{"macro(a, b) { a }", "macro(a, b) { a }", true},
+		{"macro(a) { a }", "fn(a) { a }", false},
+		{"a?.b ?? null", "(a?.b) ?? (null)", true},
+		{"a?[0]", "a[0]", false},
+		{"match (x) { [a, ...b] if a => (b) }", "match ((x)) { [a, ...b] if (a) => b, }", true},
+		{"match (x) { [a, ...b] => b }", "match (x) { [a, ..._] => b }", false},
+		{`match (x) { {"k": 1} => 1 }`, `match (x) { {"k": -1} => 1 }`, false},
+		{"match (x) { a if b => 1 }", "match (x) { a => 1 }", false},
+		{"let {a, b: c} = (x);", "let {a: a, b: c} = x;", true},
+		{"let [a] = x;", "let a = x;", false},
+		{"let {a} = x;", `let {"a": a} = x;`, false},
+		{"struct P { x, y }", "struct P { x, y };", true},
+		{"struct P { x, y }", "struct P { y, x }", false},
+		{"struct P { x }", "struct Q { x }", false},
+		{"a.b", "a?.b", false},
+		{"for (let i = 0; i < n; i += 1) { break; }", "for (let i = 0; i < n; i += 1) { continue; }", false},
+		{"for (;;) {}", "for (;;) {}", true},
+		{"while (a) {}", "while (b) {}", false},
+		{"infix 5 left <+> = f;", "infix 5 right <+> = f;", false},
+		{"a; b", "a", false},
+	}
+
+	for _, tt := range tests {
+		a, b := parse(t, tt.a), parse(t, tt.b)
+		if ast.Equal(a, b) != tt.equal || ast.Equal(b, a) != tt.equal {
+			t.Errorf("Equal(%q, %q) wrong. want = %t", tt.a, tt.b, tt.equal)
+		}
+	}
+}
+
+func TestEqualNil(t *testing.T) {
+	var block *ast.BlockStatement
+	if !ast.Equal(nil, nil) || !ast.Equal(nil, block) {
+		t.Errorf("missing nodes are not equal")
+	}
+	ident := &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}
+	if ast.Equal(ident, nil) || ast.Equal(nil, ident) {
+		t.Errorf("a node equals a missing node")
+	}
+}
@@ -0,0 +1,804 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BentleyOph/monke/token"
+)
+
+// JSONVersion is the version of the JSON schema written by MarshalJSON. It is bumped
+// whenever a change to the schema could break existing readers.
+const JSONVersion = 1
+
+// MarshalJSON encodes the tree rooted at node as JSON, so that tools written in other
+// languages can consume it. The document has the form
+//
+//	{"version": 1, "node": {...}}
+//
+// Every node is an object whose "kind" names its Go type without the package, e.g.
+// "LetStatement", followed by "pos" and "end", its byte range in the source (see
+// Node.Pos), and the fields of that kind:
+//
+//	Program              statements
+//	LetStatement         name or pattern, value, doc?
+//	ReturnStatement      value
+//	ExpressionStatement  expression
+//	BlockStatement       statements
+//	FunctionStatement    name, function, doc?
+//	InfixStatement       precedence, associativity, operator, function
+//	StructStatement      name, fields, doc?
+//	WhileStatement       condition, body
+//	ForStatement         init?, condition?, post?, body
+//	BreakStatement       (none)
+//	ContinueStatement    (none)
+//	Identifier           value (string)
+//	IntegerLiteral       value (number), literal?
+//	StringLiteral        value (string)
+//	Boolean              value (boolean)
+//	NullLiteral          (none)
+//	PrefixExpression     operator, right
+//	InfixExpression      left, operator, right
+//	IfExpression         condition, consequence, alternative?
+//	FunctionLiteral      name?, parameters, defaults?, rest?, body
+//	MacroLiteral         parameters, body
+//	CallExpression       function, arguments
+//	AssignExpression     target, operator, value
+//	IndexExpression      left, index, optional?
+//	MemberExpression     object, property, optional?
+//	MatchExpression      subject, arms
+//	MatchArm             pattern, guard?, body
+//	WildcardPattern      (none)
+//	BindingPattern       name
+//	LiteralPattern       value
+//	ArrayPattern         elements, rest?
+//	HashPattern          pairs
+//	ParenExpression      expression
+//
+// Fields marked with ? are left out when they are empty. "statements", "parameters",
+// "fields", "arguments", "arms" and "elements" are arrays of nodes, "pairs" is an array of
+// {"key": node, "value": node} objects, "defaults" is an array with the default of each of the "parameters", null where it has none,
+// "name" is an Identifier node except on FunctionLiteral where it is a string,
+// "property" is an Identifier node, "operator", "associativity" and "doc" are strings,
+// "optional" is true for the optional access forms a?[i] and a?.b, and "literal" is the
+// source text of an integer written other than in plain decimal, such as 010.
+func MarshalJSON(node Node) ([]byte, error) {
+	encoded, err := encodeNode(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(object{{"version", JSONVersion}, {"node", encoded}})
+}
+
+// UnmarshalProgram decodes a program written by MarshalJSON. The tokens of the nodes
+// are rebuilt from the encoded fields; positions of tokens that do not bound a node,
+// such as the operator of an infix expression, are not part of the schema and left 0.
+func UnmarshalProgram(data []byte) (*Program, error) {
+	var document struct {
+		Version int             `json:"version"`
+		Node    json.RawMessage `json:"node"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST JSON version %d, want %d", document.Version, JSONVersion)
+	}
+	d := &decoder{}
+	node := d.node(document.Node)
+	if d.err != nil {
+		return nil, d.err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("expected a Program node, got %s", kindOf(node))
+	}
+	return program, nil
+}
+
+// object is a JSON object that keeps its fields in order, so that "kind" comes first
+type object []field
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, f := range o {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		key, _ := json.Marshal(f.key)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+func kindOf(node Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// encodeNode encodes node and its children; nil children encode as null
+func encodeNode(node Node) (interface{}, error) {
+	e := &encoder{}
+	o := e.encode(node)
+	return o, e.err
+}
+
+type encoder struct {
+	err error
+}
+
+func (e *encoder) encode(node Node) interface{} {
+	if isNil(node) {
+		return nil
+	}
+	o := object{{"kind", kindOf(node)}, {"pos", node.Pos()}, {"end", node.End()}}
+	add := func(key string, value interface{}) {
+		o = append(o, field{key, value})
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		add("statements", e.statements(n.Statements))
+	case *LetStatement:
+		if n.Pattern != nil {
+			add("pattern", e.encode(n.Pattern))
+		} else {
+			add("name", e.encode(n.Name))
+		}
+		add("value", e.encode(n.Value))
+		if n.Doc != "" {
+			add("doc", n.Doc)
+		}
+	case *ReturnStatement:
+		add("value", e.encode(n.ReturnValue))
+	case *ExpressionStatement:
+		add("expression", e.encode(n.Expression))
+	case *BlockStatement:
+		add("statements", e.statements(n.Statements))
+	case *FunctionStatement:
+		add("name", e.encode(n.Name))
+		add("function", e.encode(n.Function))
+		if n.Doc != "" {
+			add("doc", n.Doc)
+		}
+	case *InfixStatement:
+		add("precedence", n.Precedence)
+		add("associativity", n.Associativity)
+		add("operator", n.Operator)
+		add("function", e.encode(n.Function))
+	case *StructStatement:
+		add("name", e.encode(n.Name))
+		fields := []interface{}{}
+		for _, f := range n.Fields {
+			fields = append(fields, e.encode(f))
+		}
+		add("fields", fields)
+		if n.Doc != "" {
+			add("doc", n.Doc)
+		}
+	case *WhileStatement:
+		add("condition", e.encode(n.Condition))
+		add("body", e.encode(n.Body))
+	case *ForStatement:
+		if n.Init != nil {
+			add("init", e.encode(n.Init))
+		}
+		if n.Condition != nil {
+			add("condition", e.encode(n.Condition))
+		}
+		if n.Post != nil {
+			add("post", e.encode(n.Post))
+		}
+		add("body", e.encode(n.Body))
+	case *BreakStatement, *ContinueStatement:
+	case *Identifier:
+		add("value", n.Value)
+	case *IntegerLiteral:
+		add("value", n.Value)
+		if n.Token.Literal != strconv.FormatInt(n.Value, 10) {
+			add("literal", n.Token.Literal)
+		}
+	case *StringLiteral:
+		add("value", n.Value)
+	case *Boolean:
+		add("value", n.Value)
+	case *NullLiteral:
+	case *PrefixExpression:
+		add("operator", n.Operator)
+		add("right", e.encode(n.Right))
+	case *InfixExpression:
+		add("left", e.encode(n.Left))
+		add("operator", n.Operator)
+		add("right", e.encode(n.Right))
+	case *IfExpression:
+		add("condition", e.encode(n.Condition))
+		add("consequence", e.encode(n.Consequence))
+		if n.Alternative != nil {
+			add("alternative", e.encode(n.Alternative))
+		}
+	case *FunctionLiteral:
+		if n.Name != "" {
+			add("name", n.Name)
+		}
+		params := []interface{}{}
+		for _, param := range n.Parameters {
+			params = append(params, e.encode(param))
+		}
+		add("parameters", params)
+		if len(n.Defaults) > 0 {
+			defaults := []interface{}{}
+			for i := range n.Parameters {
+				if value := n.Default(i); value != nil {
+					defaults = append(defaults, e.encode(value))
+				} else {
+					defaults = append(defaults, nil)
+				}
+			}
+			add("defaults", defaults)
+		}
+		if n.Rest != nil {
+			add("rest", e.encode(n.Rest))
+		}
+		add("body", e.encode(n.Body))
+	case *MacroLiteral:
+		params := []interface{}{}
+		for _, param := range n.Parameters {
+			params = append(params, e.encode(param))
+		}
+		add("parameters", params)
+		add("body", e.encode(n.Body))
+	case *CallExpression:
+		add("function", e.encode(n.Function))
+		args := []interface{}{}
+		for _, arg := range n.Arguments {
+			args = append(args, e.encode(arg))
+		}
+		add("arguments", args)
+	case *AssignExpression:
+		add("target", e.encode(n.Target))
+		add("operator", n.Operator)
+		add("value", e.encode(n.Value))
+	case *IndexExpression:
+		add("left", e.encode(n.Left))
+		add("index", e.encode(n.Index))
+		if n.Optional {
+			add("optional", true)
+		}
+	case *MemberExpression:
+		add("object", e.encode(n.Object))
+		add("property", e.encode(n.Property))
+		if n.Optional {
+			add("optional", true)
+		}
+	case *ParenExpression:
+		add("expression", e.encode(n.Expression))
+	case *MatchExpression:
+		add("subject", e.encode(n.Subject))
+		arms := []interface{}{}
+		for _, arm := range n.Arms {
+			arms = append(arms, e.encode(arm))
+		}
+		add("arms", arms)
+	case *MatchArm:
+		add("pattern", e.encode(n.Pattern))
+		if n.Guard != nil {
+			add("guard", e.encode(n.Guard))
+		}
+		add("body", e.encode(n.Body))
+	case *WildcardPattern:
+	case *BindingPattern:
+		add("name", e.encode(n.Name))
+	case *LiteralPattern:
+		add("value", e.encode(n.Value))
+	case *ArrayPattern:
+		elements := []interface{}{}
+		for _, element := range n.Elements {
+			elements = append(elements, e.encode(element))
+		}
+		add("elements", elements)
+		if n.Rest != nil {
+			add("rest", e.encode(n.Rest))
+		}
+	case *HashPattern:
+		pairs := []interface{}{}
+		for _, pair := range n.Pairs {
+			pairs = append(pairs, object{{"key", e.encode(pair.Key)}, {"value", e.encode(pair.Value)}})
+		}
+		add("pairs", pairs)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot encode node type %T as JSON", node)
+		}
+	}
+	return o
+}
+
+func (e *encoder) statements(statements []Statement) []interface{} {
+	encoded := []interface{}{}
+	for _, s := range statements {
+		encoded = append(encoded, e.encode(s))
+	}
+	return encoded
+}
+
+// decoder turns JSON nodes back into a tree. It remembers the first error and
+// returns nil nodes from then on, so that callers only check err once at the end.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// fields are the members of one encoded node
+type fields struct {
+	d      *decoder
+	kind   string
+	values map[string]json.RawMessage
+}
+
+func (f *fields) has(key string) bool {
+	value, ok := f.values[key]
+	return ok && string(value) != "null"
+}
+
+// get unmarshals the member key into v, failing when it is missing
+func (f *fields) get(key string, v interface{}) {
+	if f.d.err != nil {
+		return
+	}
+	value, ok := f.values[key]
+	if !ok {
+		f.d.fail("%s is missing %q", f.kind, key)
+		return
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		f.d.fail("%s.%s: %s", f.kind, key, err)
+	}
+}
+
+func (f *fields) int(key string) int {
+	var v int
+	f.get(key, &v)
+	return v
+}
+
+func (f *fields) string(key string) string {
+	var v string
+	f.get(key, &v)
+	return v
+}
+
+// doc returns the optional "doc" member
+func (f *fields) doc() string {
+	if !f.has("doc") {
+		return ""
+	}
+	return f.string("doc")
+}
+
+// optional returns the optional "optional" member
+func (f *fields) optional() bool {
+	var v bool
+	if f.has("optional") {
+		f.get("optional", &v)
+	}
+	return v
+}
+
+func (f *fields) node(key string) Node {
+	var raw json.RawMessage
+	f.get(key, &raw)
+	return f.d.node(raw)
+}
+
+func (f *fields) nodes(key string) []Node {
+	var raws []json.RawMessage
+	f.get(key, &raws)
+	nodes := []Node{}
+	for _, raw := range raws {
+		nodes = append(nodes, f.d.node(raw))
+	}
+	return nodes
+}
+
+func (f *fields) expression(key string) Expression {
+	return f.d.expression(f.node(key), f.kind+"."+key)
+}
+
+func (f *fields) statement(key string) Statement {
+	return f.d.statement(f.node(key), f.kind+"."+key)
+}
+
+func (f *fields) statements(key string) []Statement {
+	statements := []Statement{}
+	for i, node := range f.nodes(key) {
+		statements = append(statements, f.d.statement(node, fmt.Sprintf("%s.%s[%d]", f.kind, key, i)))
+	}
+	return statements
+}
+
+func (f *fields) identifier(key string) *Identifier {
+	ident, ok := f.node(key).(*Identifier)
+	if !ok {
+		f.d.fail("%s.%s is not an Identifier", f.kind, key)
+	}
+	return ident
+}
+
+func (f *fields) block(key string) *BlockStatement {
+	block, ok := f.node(key).(*BlockStatement)
+	if !ok {
+		f.d.fail("%s.%s is not a BlockStatement", f.kind, key)
+	}
+	return block
+}
+
+func (f *fields) pattern(key string) Pattern {
+	return f.d.pattern(f.node(key), f.kind+"."+key)
+}
+
+func (d *decoder) pattern(node Node, path string) Pattern {
+	pattern, ok := node.(Pattern)
+	if !ok {
+		d.fail("%s is not a pattern", path)
+	}
+	return pattern
+}
+
+func (d *decoder) expression(node Node, path string) Expression {
+	e, ok := node.(Expression)
+	if !ok {
+		d.fail("%s is not an expression", path)
+	}
+	return e
+}
+
+func (d *decoder) statement(node Node, path string) Statement {
+	s, ok := node.(Statement)
+	if !ok {
+		d.fail("%s is not a statement", path)
+	}
+	return s
+}
+
+// keyword returns a token of tokenType that starts at pos and reads literal
+func keyword(tokenType token.TokenType, literal string, pos int) token.Token {
+	return token.Token{Type: tokenType, Literal: literal, Pos: pos, End: pos + len(literal)}
+}
+
+// closing returns the single character token that ends at end
+func closing(tokenType token.TokenType, end int) token.Token {
+	return token.Token{Type: tokenType, Literal: string(tokenType), Pos: end - 1, End: end}
+}
+
+// firstToken returns the token an expression starts with
+func firstToken(e Expression) token.Token {
+	switch e := e.(type) {
+	case *InfixExpression:
+		return firstToken(e.Left)
+	case *CallExpression:
+		return firstToken(e.Function)
+	case *AssignExpression:
+		return firstToken(e.Target)
+	case *IndexExpression:
+		return firstToken(e.Left)
+	case *MemberExpression:
+		return firstToken(e.Object)
+	case *Identifier:
+		return e.Token
+	case *IntegerLiteral:
+		return e.Token
+	case *StringLiteral:
+		return e.Token
+	case *Boolean:
+		return e.Token
+	case *NullLiteral:
+		return e.Token
+	case *PrefixExpression:
+		return e.Token
+	case *IfExpression:
+		return e.Token
+	case *FunctionLiteral:
+		return e.Token
+	case *MacroLiteral:
+		return e.Token
+	case *MatchExpression:
+		return e.Token
+	case *ParenExpression:
+		return e.Token
+	}
+	return token.Token{}
+}
+
+func (d *decoder) node(raw json.RawMessage) Node {
+	if d.err != nil {
+		return nil
+	}
+	f := &fields{d: d, kind: "node"}
+	if err := json.Unmarshal(raw, &f.values); err != nil {
+		d.fail("invalid node: %s", err)
+		return nil
+	}
+	if f.values == nil {
+		d.fail("unexpected null node")
+		return nil
+	}
+	f.kind = f.string("kind")
+	pos, end := f.int("pos"), f.int("end")
+	if d.err != nil {
+		return nil
+	}
+
+	var node Node
+	switch f.kind {
+	case "Program":
+		node = &Program{Statements: f.statements("statements")}
+	case "LetStatement":
+		let := &LetStatement{Token: keyword(token.LET, "let", pos)}
+		if f.has("pattern") {
+			let.Pattern = f.pattern("pattern")
+		} else {
+			let.Name = f.identifier("name")
+		}
+		let.Value = f.expression("value")
+		let.Doc = f.doc()
+		node = let
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: keyword(token.RETURN, "return", pos), ReturnValue: f.expression("value")}
+	case "ExpressionStatement":
+		expression := f.expression("expression")
+		node = &ExpressionStatement{Token: firstToken(expression), Expression: expression}
+	case "BlockStatement":
+		node = &BlockStatement{
+			Token:      keyword(token.LBRACE, "{", pos),
+			Statements: f.statements("statements"),
+			Rbrace:     closing(token.RBRACE, end),
+		}
+	case "FunctionStatement":
+		stmt := &FunctionStatement{Token: keyword(token.FUNCTION, "fn", pos), Name: f.identifier("name"), Doc: f.doc()}
+		stmt.Function, _ = f.node("function").(*FunctionLiteral)
+		if stmt.Function == nil {
+			d.fail("FunctionStatement.function is not a FunctionLiteral")
+		}
+		node = stmt
+	case "InfixStatement":
+		node = &InfixStatement{
+			Token:         keyword(token.INFIX, "infix", pos),
+			Precedence:    f.int("precedence"),
+			Associativity: f.string("associativity"),
+			Operator:      f.string("operator"),
+			Function:      f.expression("function"),
+		}
+	case "StructStatement":
+		stmt := &StructStatement{Token: keyword(token.STRUCT, "struct", pos), Name: f.identifier("name"), Fields: []*Identifier{}}
+		for i, field := range f.nodes("fields") {
+			ident, ok := field.(*Identifier)
+			if !ok {
+				d.fail("StructStatement.fields[%d] is not an Identifier", i)
+			}
+			stmt.Fields = append(stmt.Fields, ident)
+		}
+		stmt.Rbrace = closing(token.RBRACE, end)
+		stmt.Doc = f.doc()
+		node = stmt
+	case "WhileStatement":
+		node = &WhileStatement{Token: keyword(token.WHILE, "while", pos), Condition: f.expression("condition"), Body: f.block("body")}
+	case "ForStatement":
+		stmt := &ForStatement{Token: keyword(token.FOR, "for", pos)}
+		if f.has("init") {
+			stmt.Init = f.statement("init")
+		}
+		if f.has("condition") {
+			stmt.Condition = f.expression("condition")
+		}
+		if f.has("post") {
+			stmt.Post = f.expression("post")
+		}
+		stmt.Body = f.block("body")
+		node = stmt
+	case "BreakStatement":
+		node = &BreakStatement{Token: keyword(token.BREAK, "break", pos)}
+	case "ContinueStatement":
+		node = &ContinueStatement{Token: keyword(token.CONTINUE, "continue", pos)}
+	case "Identifier":
+		value := f.string("value")
+		node = &Identifier{Token: token.Token{Type: token.IDENT, Literal: value, Pos: pos, End: end}, Value: value}
+	case "IntegerLiteral":
+		var value int64
+		f.get("value", &value)
+		literal := strconv.FormatInt(value, 10)
+		if f.has("literal") {
+			literal = f.string("literal")
+			if parsed, err := strconv.ParseInt(literal, 0, 64); err != nil || parsed != value {
+				f.d.fail("IntegerLiteral.literal %q does not match value %d", literal, value)
+			}
+		}
+		node = &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Pos: pos, End: end}, Value: value}
+	case "StringLiteral":
+		value := f.string("value")
+		node = &StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: pos, End: end}, Value: value}
+	case "Boolean":
+		var value bool
+		f.get("value", &value)
+		tok := keyword(token.FALSE, "false", pos)
+		if value {
+			tok = keyword(token.TRUE, "true", pos)
+		}
+		node = &Boolean{Token: tok, Value: value}
+	case "NullLiteral":
+		node = &NullLiteral{Token: keyword(token.NULL, "null", pos)}
+	case "PrefixExpression":
+		operator := f.string("operator")
+		node = &PrefixExpression{Token: keyword(token.TokenType(operator), operator, pos), Operator: operator, Right: f.expression("right")}
+	case "InfixExpression":
+		left := f.expression("left")
+		operator := f.string("operator")
+		node = &InfixExpression{
+			Token:    token.Token{Type: token.TokenType(operator), Literal: operator},
+			Left:     left,
+			Operator: operator,
+			Right:    f.expression("right"),
+		}
+	case "IfExpression":
+		expression := &IfExpression{Token: keyword(token.IF, "if", pos), Condition: f.expression("condition"), Consequence: f.block("consequence")}
+		if f.has("alternative") {
+			expression.Alternative = f.block("alternative")
+		}
+		node = expression
+	case "FunctionLiteral":
+		lit := &FunctionLiteral{Token: keyword(token.FUNCTION, "fn", pos), Parameters: []*Identifier{}}
+		if f.has("name") {
+			lit.Name = f.string("name")
+		}
+		for i, param := range f.nodes("parameters") {
+			ident, ok := param.(*Identifier)
+			if !ok {
+				d.fail("FunctionLiteral.parameters[%d] is not an Identifier", i)
+			}
+			lit.Parameters = append(lit.Parameters, ident)
+		}
+		if f.has("defaults") {
+			var raws []json.RawMessage
+			f.get("defaults", &raws)
+			if len(raws) != len(lit.Parameters) {
+				d.fail("FunctionLiteral.defaults has %d entries for %d parameters", len(raws), len(lit.Parameters))
+			}
+			lit.Defaults = make([]Expression, len(raws))
+			for i, raw := range raws {
+				if string(raw) != "null" {
+					lit.Defaults[i] = d.expression(d.node(raw), fmt.Sprintf("FunctionLiteral.defaults[%d]", i))
+				}
+			}
+		}
+		if f.has("rest") {
+			lit.Rest = f.identifier("rest")
+		}
+		lit.Body = f.block("body")
+		node = lit
+	case "MacroLiteral":
+		lit := &MacroLiteral{Token: keyword(token.MACRO, "macro", pos), Parameters: []*Identifier{}}
+		for i, param := range f.nodes("parameters") {
+			ident, ok := param.(*Identifier)
+			if !ok {
+				d.fail("MacroLiteral.parameters[%d] is not an Identifier", i)
+			}
+			lit.Parameters = append(lit.Parameters, ident)
+		}
+		lit.Body = f.block("body")
+		node = lit
+	case "CallExpression":
+		exp := &CallExpression{Token: token.Token{Type: token.LPAREN, Literal: "("}, Function: f.expression("function"), Arguments: []Expression{}}
+		for i, arg := range f.nodes("arguments") {
+			exp.Arguments = append(exp.Arguments, d.expression(arg, fmt.Sprintf("CallExpression.arguments[%d]", i)))
+		}
+		exp.Rparen = closing(token.RPAREN, end)
+		node = exp
+	case "AssignExpression":
+		target := f.expression("target")
+		operator := f.string("operator")
+		node = &AssignExpression{
+			Token:    token.Token{Type: token.TokenType(operator), Literal: operator},
+			Target:   target,
+			Operator: operator,
+			Value:    f.expression("value"),
+		}
+	case "IndexExpression":
+		exp := &IndexExpression{
+			Token:    token.Token{Type: token.LBRACKET, Literal: "["},
+			Left:     f.expression("left"),
+			Index:    f.expression("index"),
+			Rbracket: closing(token.RBRACKET, end),
+			Optional: f.optional(),
+		}
+		if exp.Optional {
+			exp.Token = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		}
+		node = exp
+	case "MemberExpression":
+		exp := &MemberExpression{
+			Token:    token.Token{Type: token.DOT, Literal: "."},
+			Object:   f.expression("object"),
+			Property: f.identifier("property"),
+			Optional: f.optional(),
+		}
+		if exp.Optional {
+			exp.Token = token.Token{Type: token.OPTIONAL_DOT, Literal: "?."}
+		}
+		node = exp
+	case "ParenExpression":
+		node = &ParenExpression{Token: keyword(token.LPAREN, "(", pos), Expression: f.expression("expression"), Rparen: closing(token.RPAREN, end)}
+	case "MatchExpression":
+		exp := &MatchExpression{Token: keyword(token.MATCH, "match", pos), Subject: f.expression("subject"), Arms: []*MatchArm{}}
+		for i, arm := range f.nodes("arms") {
+			matchArm, ok := arm.(*MatchArm)
+			if !ok {
+				d.fail("MatchExpression.arms[%d] is not a MatchArm", i)
+			}
+			exp.Arms = append(exp.Arms, matchArm)
+		}
+		exp.Rbrace = closing(token.RBRACE, end)
+		node = exp
+	case "MatchArm":
+		arm := &MatchArm{Pattern: f.pattern("pattern"), Token: token.Token{Type: token.ARROW, Literal: "=>"}}
+		if f.has("guard") {
+			arm.Guard = f.expression("guard")
+		}
+		arm.Body = f.expression("body")
+		node = arm
+	case "WildcardPattern":
+		node = &WildcardPattern{Token: keyword(token.IDENT, "_", pos)}
+	case "BindingPattern":
+		node = &BindingPattern{Name: f.identifier("name")}
+	case "LiteralPattern":
+		node = &LiteralPattern{Value: f.expression("value")}
+	case "ArrayPattern":
+		pattern := &ArrayPattern{Token: keyword(token.LBRACKET, "[", pos), Elements: []Pattern{}}
+		for i, element := range f.nodes("elements") {
+			pattern.Elements = append(pattern.Elements, d.pattern(element, fmt.Sprintf("ArrayPattern.elements[%d]", i)))
+		}
+		if f.has("rest") {
+			pattern.Rest = f.identifier("rest")
+		}
+		pattern.Rbracket = closing(token.RBRACKET, end)
+		node = pattern
+	case "HashPattern":
+		pattern := &HashPattern{Token: keyword(token.LBRACE, "{", pos), Pairs: []*HashPatternPair{}}
+		var raws []json.RawMessage
+		f.get("pairs", &raws)
+		for i, raw := range raws {
+			pair := &fields{d: d, kind: fmt.Sprintf("HashPattern.pairs[%d]", i)}
+			if err := json.Unmarshal(raw, &pair.values); err != nil {
+				d.fail("invalid %s: %s", pair.kind, err)
+				break
+			}
+			pattern.Pairs = append(pattern.Pairs, &HashPatternPair{Key: pair.expression("key"), Value: pair.pattern("value")})
+		}
+		pattern.Rbrace = closing(token.RBRACE, end)
+		node = pattern
+	default:
+		d.fail("unknown node kind %q", f.kind)
+	}
+	if d.err != nil {
+		return nil
+	}
+	return node
+}
@@ -0,0 +1,139 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"let x = 5; return x;",
+		"let x = 010; 0x10",
+		`let s = "hello world"; s`,
+		"-a * b + !true == false",
+		"(1 + 2) * 3 ** 2",
+		"if (a < b) { a } else { b }",
+		"if (a) { b }",
+		"let add = fn(a, b = 2, ...rest) { a + b }; add(1)[0]",
+		"fn even(n) { if (n == 0) { true } else { odd(n - 1) } }",
+		"while (x > 0) { x -= 1; if (x == 5) { break; } continue; }",
+		"for (let i = 0; i < 10; i += 1) { a[i] = i * i; }",
+		"for (;;) { }",
+		"for (i = 0;;) { }",
+		"infix 5 right <+> = fn(a, b) { a }; 1 <+> 2 <+> 3",
+		"let host = cfg?.db?.host ?? null; a?[0][1]",
+		`match (x) { 0 => null, [a, ...r] if a > 0 => r, {"k": -1, 2: [_]} => 1, y => y }`,
+		"let [a, ...r] = xs; let {name, age: years, \"k\": [_]} = person;",
+		"/// A point.\nstruct Point { x, y } struct Unit {} p.x = p?.y",
+		"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { a } else { b }) }; unless(x, 1, 2)",
+		"/// Five.\nlet x = 5;\n/// Doubles.\n///\n/// Really.\nfn f(x) { x * 2 }",
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			t.Fatalf("MarshalJSON(%q) failed: %s", input, err)
+		}
+		decoded, err := ast.UnmarshalProgram(data)
+		if err != nil {
+			t.Fatalf("UnmarshalProgram failed for %q: %s\n%s", input, err, data)
+		}
+		if decoded.String() != program.String() {
+			t.Errorf("round trip of %q changed the program. want = %q, got = %q", input, program.String(), decoded.String())
+		}
+		if want, got := docs(program), docs(decoded); want != got {
+			t.Errorf("round trip of %q changed the doc comments. want = %q, got = %q", input, want, got)
+		}
+		if want, got := spans(program), spans(decoded); want != got {
+			t.Errorf("round trip of %q changed the spans.\nwant = %s\ngot  = %s", input, want, got)
+		}
+	}
+}
+
+// spans lists the type and source range of every node in program
+func spans(program *ast.Program) string {
+	var out []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			out = append(out, fmt.Sprintf("%T[%d:%d]", node, node.Pos(), node.End()))
+		}
+		return true
+	})
+	return strings.Join(out, " ")
+}
+
+// docs lists the doc comments of the top-level declarations of program
+func docs(program *ast.Program) string {
+	var out []string
+	for _, s := range program.Statements {
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			out = append(out, s.Doc)
+		case *ast.FunctionStatement:
+			out = append(out, s.Doc)
+		}
+	}
+	return strings.Join(out, "|")
+}
+
+func TestMarshalJSON(t *testing.T) {
+	data, err := ast.MarshalJSON(parse(t, "let x = -1;"))
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %s", err)
+	}
+	expected := `{"version":1,"node":{"kind":"Program","pos":0,"end":10,"statements":[` +
+		`{"kind":"LetStatement","pos":0,"end":10,` +
+		`"name":{"kind":"Identifier","pos":4,"end":5,"value":"x"},` +
+		`"value":{"kind":"PrefixExpression","pos":8,"end":10,"operator":"-",` +
+		`"right":{"kind":"IntegerLiteral","pos":9,"end":10,"value":1}}}]}}`
+	if string(data) != expected {
+		t.Errorf("wrong JSON.\nwant = %s\ngot  = %s", expected, data)
+	}
+}
+
+func TestMarshalJSONDefaults(t *testing.T) {
+	data, err := ast.MarshalJSON(parse(t, "fn(a, b = 1) {}"))
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %s", err)
+	}
+	expected := `"defaults":[null,{"kind":"IntegerLiteral","pos":10,"end":11,"value":1}]`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("defaults are not aligned with the parameters. want %s in %s", expected, data)
+	}
+}
+
+func TestUnmarshalProgramErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{"version":2,"node":{"kind":"Program","pos":0,"end":0,"statements":[]}}`, "unsupported AST JSON version 2, want 1"},
+		{`{"version":1,"node":{"kind":"Identifier","pos":0,"end":1,"value":"x"}}`, "expected a Program node, got Identifier"},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":0,"statements":[{"kind":"Nope","pos":0,"end":0}]}}`, `unknown node kind "Nope"`},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":0}}`, `Program is missing "statements"`},
+		{`{"version":1,"node":{"pos":0,"end":0}}`, `node is missing "kind"`},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":1,"statements":[{"kind":"Identifier","pos":0,"end":1,"value":"x"}]}}`,
+			"Program.statements[0] is not a statement"},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":1,"statements":[{"kind":"ExpressionStatement","pos":0,"end":1,"expression":null}]}}`,
+			"unexpected null node"},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":1,"statements":[{"kind":"ExpressionStatement","pos":0,"end":1,"expression":` +
+			`{"kind":"FunctionLiteral","pos":0,"end":1,"parameters":[{"kind":"Identifier","pos":0,"end":1,"value":"a"}],"defaults":[null,null],` +
+			`"body":{"kind":"BlockStatement","pos":0,"end":1,"statements":[]}}}]}}`,
+			"FunctionLiteral.defaults has 2 entries for 1 parameters"},
+		{`{"version":1,"node":{"kind":"Program","pos":0,"end":3,"statements":[{"kind":"ExpressionStatement","pos":0,"end":3,"expression":` +
+			`{"kind":"IntegerLiteral","pos":0,"end":3,"value":10,"literal":"010"}}]}}`,
+			`IntegerLiteral.literal "010" does not match value 10`},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.UnmarshalProgram([]byte(tt.input))
+		if err == nil || err.Error() != tt.expectedError {
+			t.Errorf("wrong error for %s. want = %q, got = %v", tt.input, tt.expectedError, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc is called by Modify for every node of a tree and returns the node that
+// takes its place, which may be the node itself
+type ModifierFunc func(Node) Node
+
+// Modify rebuilds the tree rooted at node bottom-up: the children of a node are
+// modified before modifier is called for the node itself, and every child is replaced
+// in place by what modifier returned for it. Modify returns what modifier returned
+// for node.
+//
+// The names a node declares, i.e. the name or pattern of a let statement, the name of
+// a function declaration, the name and fields of a struct and the parameters of a
+// function, belong to that node and are not passed to modifier, so that replacing
+// every use of an identifier leaves its declarations intact. Neither
+// is the property name of a member expression, which is not a use of a variable, nor
+// a match arm with its pattern, of which only the guard and body are modified.
+//
+// A replacement has to fit the place of the node it replaces: an expression can only
+// be replaced by an expression, a statement by a statement, and the nodes stored with
+// a concrete type, such as the *BlockStatement of a loop or the *FunctionLiteral of a
+// function declaration, by a node of that type. Modify panics otherwise.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, modifier)
+
+	// Statements
+	case *LetStatement:
+		n.Value = modify(n.Value, modifier)
+	case *ReturnStatement:
+		n.ReturnValue = modify(n.ReturnValue, modifier)
+	case *ExpressionStatement:
+		n.Expression = modify(n.Expression, modifier)
+	case *BlockStatement:
+		modifyStatements(n.Statements, modifier)
+	case *FunctionStatement:
+		n.Function = modify(n.Function, modifier)
+	case *InfixStatement:
+		n.Function = modify(n.Function, modifier)
+	case *WhileStatement:
+		n.Condition = modify(n.Condition, modifier)
+		n.Body = modify(n.Body, modifier)
+	case *ForStatement:
+		if n.Init != nil {
+			n.Init = modify(n.Init, modifier)
+		}
+		if n.Condition != nil {
+			n.Condition = modify(n.Condition, modifier)
+		}
+		if n.Post != nil {
+			n.Post = modify(n.Post, modifier)
+		}
+		n.Body = modify(n.Body, modifier)
+
+	// Expressions
+	case *PrefixExpression:
+		n.Right = modify(n.Right, modifier)
+	case *InfixExpression:
+		n.Left = modify(n.Left, modifier)
+		n.Right = modify(n.Right, modifier)
+	case *IfExpression:
+		n.Condition = modify(n.Condition, modifier)
+		n.Consequence = modify(n.Consequence, modifier)
+		if n.Alternative != nil {
+			n.Alternative = modify(n.Alternative, modifier)
+		}
+	case *FunctionLiteral:
+		for i, value := range n.Defaults {
+			if value != nil {
+				n.Defaults[i] = modify(value, modifier)
+			}
+		}
+		n.Body = modify(n.Body, modifier)
+	case *MacroLiteral:
+		n.Body = modify(n.Body, modifier)
+	case *CallExpression:
+		n.Function = modify(n.Function, modifier)
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = modify(arg, modifier)
+		}
+	case *AssignExpression:
+		n.Target = modify(n.Target, modifier)
+		n.Value = modify(n.Value, modifier)
+	case *IndexExpression:
+		n.Left = modify(n.Left, modifier)
+		n.Index = modify(n.Index, modifier)
+	case *MemberExpression:
+		n.Object = modify(n.Object, modifier)
+	case *MatchExpression:
+		n.Subject = modify(n.Subject, modifier)
+		for _, arm := range n.Arms {
+			if arm.Guard != nil {
+				arm.Guard = modify(arm.Guard, modifier)
+			}
+			arm.Body = modify(arm.Body, modifier)
+		}
+	case *ParenExpression:
+		n.Expression = modify(n.Expression, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) {
+	for i, s := range statements {
+		statements[i] = modify(s, modifier)
+	}
+}
+
+// modify modifies node and checks that the result fits where node was stored
+func modify[T Node](node T, modifier ModifierFunc) T {
+	modified := Modify(node, modifier)
+	result, ok := modified.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: %T cannot replace %T", modified, node))
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/token"
+)
+
+func TestModify(t *testing.T) {
+	one := func() ast.Expression { return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1} }
+	two := func() ast.Expression { return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2} }
+
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 1", "(2 + 2)"},
+		{"-1", "(-2)"},
+		{"(1) * 3", "(2 * 3)"},
+		{"let x = 1;", "let x = 2;"},
+		{"return 1;", "return 2;"},
+		{"if (1) { 1 } else { 1 }", "if (2) { 2 } else { 2 }"},
+		{"f(1, 3, 1)", "f(2, 3, 2)"},
+		{"fn(a = 1) { 1 }", "fn(a = 2) { 2 }"},
+		{"fn f() { 1 }", "fn f() { 2 }"},
+		{"while (1) { 1; }", "while (2) { 2 }"},
+		{"for (let i = 1; i < 1; i += 1) { 1 }", "for (let i = 2; (i < 2); (i += 2)) { 2 }"},
+		{"a[1] = 1", "((a[2]) = 2)"},
+		{"a?[1]?.b ?? 1", "(((a?[2])?.b) ?? 2)"},
+		{"match (1) { 1 if 1 => 1 }", "match (2) { 1 if 2 => 2 }"},
+		{"infix 5 left <+> = fn(a, b) { 1 };", "infix 5 left <+> = fn(a, b) { 2 };"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		modified := ast.Modify(program, turnOneIntoTwo)
+		if modified != ast.Node(program) {
+			t.Errorf("Modify did not return the program for %q", tt.input)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("wrong result for %q. want = %q, got = %q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	// the root itself is replaced too
+	if modified := ast.Modify(one(), turnOneIntoTwo); modified.String() != "2" {
+		t.Errorf("root not modified. got = %q", modified.String())
+	}
+}
+
+func TestModifyKeepsDeclarations(t *testing.T) {
+	program := parse(t, "let x = x; let [x, {x: y}] = x; struct x { x } fn f(x, ...y) { x + y?.x + match (x) { [x] => x } }")
+	renamed := ast.Modify(program, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: strings.ToUpper(ident.Value)}, Value: strings.ToUpper(ident.Value)}
+		}
+		return node
+	})
+	expected := "let x = X;let [x, {x: y}] = X;struct x { x }fn f(x, ...y) { ((X + (Y?.x)) + match (X) { [x] => X }) }"
+	if renamed.String() != expected {
+		t.Errorf("wrong result. want = %q, got = %q", expected, renamed.String())
+	}
+}
+
+func TestModifyRejectsMisplacedNodes(t *testing.T) {
+	program := parse(t, "while (x) { x }")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when replacing a block with an expression")
+		}
+	}()
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.BlockStatement); ok {
+			return &ast.Identifier{Value: "x"}
+		}
+		return node
+	})
+}
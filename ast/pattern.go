@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/BentleyOph/monke/token"
+)
+
+// Pattern describes the shape of a value, such as [first, ...rest], and names the
+// parts of it that are bound when a value has that shape
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// WildcardPattern matches any value without binding it: _
+type WildcardPattern struct {
+	Token token.Token // The '_' token
+}
+
+func (wp *WildcardPattern) patternNode() {}
+func (wp *WildcardPattern) TokenLiteral() string {
+	return wp.Token.Literal
+}
+func (wp *WildcardPattern) String() string {
+	return "_"
+}
+
+// BindingPattern matches any value and binds it to a name: n
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) patternNode() {}
+func (bp *BindingPattern) TokenLiteral() string {
+	return bp.Name.TokenLiteral()
+}
+func (bp *BindingPattern) String() string {
+	return nodeString(bp.Name)
+}
+
+// LiteralPattern matches the values equal to an integer, string, boolean or null
+// literal. A negative integer is stored as the PrefixExpression -n.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode() {}
+func (lp *LiteralPattern) TokenLiteral() string {
+	return lp.Value.TokenLiteral()
+}
+func (lp *LiteralPattern) String() string {
+	return literalString(lp.Value)
+}
+
+// literalString prints the literal of a pattern, -1 rather than (-1), which is not a pattern
+func literalString(literal Expression) string {
+	if minus, ok := literal.(*PrefixExpression); ok {
+		return minus.Operator + nodeString(minus.Right)
+	}
+	return nodeString(literal)
+}
+
+// ArrayPattern matches arrays element by element: [a, b] matches the arrays of two
+// elements, and [a, ...rest] those of at least one, binding the others to rest
+type ArrayPattern struct {
+	Token    token.Token // The '[' token
+	Elements []Pattern
+	Rest     *Identifier // nil without ...rest; ..._ allows more elements without binding them
+	Rbracket token.Token // The ']' token
+}
+
+func (ap *ArrayPattern) patternNode() {}
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+func (ap *ArrayPattern) String() string {
+	items := []string{}
+	for _, e := range ap.Elements {
+		items = append(items, nodeString(e))
+	}
+	if ap.Rest != nil {
+		items = append(items, "..."+ap.Rest.Value)
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// HashPattern matches the hashes that have all of its keys, with values that match
+// the patterns of the keys; other keys are ignored: {"type": "user", "name": n}.
+// A name on its own, as in {name, age: years}, is short for name: name.
+type HashPattern struct {
+	Token  token.Token // The '{' token
+	Pairs  []*HashPatternPair
+	Rbrace token.Token // The '}' token
+}
+
+// HashPatternPair is one key: pattern entry of a HashPattern
+type HashPatternPair struct {
+	Key   Expression // a string, integer or boolean literal, a negated integer, or an identifier standing for the string of its name
+	Value Pattern
+}
+
+func (hp *HashPattern) patternNode() {}
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+func (hp *HashPattern) String() string {
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, pair := range hp.Pairs {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(literalString(pair.Key))
+		if !pair.shorthand() {
+			out.WriteString(": ")
+			out.WriteString(nodeString(pair.Value))
+		}
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// shorthand reports whether the pair binds the value of a name key to that name
+func (pair *HashPatternPair) shorthand() bool {
+	key, ok := pair.Key.(*Identifier)
+	if !ok {
+		return false
+	}
+	value, ok := pair.Value.(*BindingPattern)
+	return ok && value.Name != nil && value.Name.Value == key.Value
+}
+
+// Bindings returns the names pattern binds, in source order
+func Bindings(pattern Pattern) []*Identifier {
+	names := []*Identifier{}
+	var collect func(Pattern)
+	collect = func(pattern Pattern) {
+		switch p := pattern.(type) {
+		case *BindingPattern:
+			names = append(names, p.Name)
+		case *ArrayPattern:
+			for _, e := range p.Elements {
+				collect(e)
+			}
+			if p.Rest != nil && p.Rest.Value != "_" {
+				names = append(names, p.Rest)
+			}
+		case *HashPattern:
+			for _, pair := range p.Pairs {
+				collect(pair.Value)
+			}
+		}
+	}
+	collect(pattern)
+	return names
+}
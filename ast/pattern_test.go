@@ -0,0 +1,34 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+)
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"_", ""},
+		{"x", "x"},
+		{"-1", ""},
+		{"[a, [b, _], ...rest]", "a b rest"},
+		{"[a, ..._]", "a"},
+		{`{"k": [a], 1: {"n": b}, true: null}`, "a b"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, "match (v) { "+tt.pattern+" => 0 }")
+		match := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+		var names []string
+		for _, name := range ast.Bindings(match.Arms[0].Pattern) {
+			names = append(names, name.Value)
+		}
+		if actual := strings.Join(names, " "); actual != tt.expected {
+			t.Errorf("wrong bindings of %s. want = %q, got = %q", tt.pattern, tt.expected, actual)
+		}
+	}
+}
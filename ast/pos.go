@@ -0,0 +1,229 @@
+package ast
+
+// Pos and End map every node back to the byte range of the source it was parsed from,
+// so that input[node.Pos():node.End()] is the text of node. A statement starts with its
+// first token and ends with its last expression or block, without a trailing semicolon.
+// Parentheses around an expression are covered by the *ParenExpression that holds it.
+//
+// Nodes missing from a hand-built tree are skipped, so the range of a partially
+// built node covers the children it has.
+
+// posOf returns node.Pos(), or otherwise when node is missing
+func posOf(node Node, otherwise int) int {
+	if isNil(node) {
+		return otherwise
+	}
+	return node.Pos()
+}
+
+// endOf returns node.End(), or otherwise when node is missing
+func endOf(node Node, otherwise int) int {
+	if isNil(node) {
+		return otherwise
+	}
+	return node.End()
+}
+
+func (p *Program) Pos() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+	return posOf(p.Statements[0], 0)
+}
+func (p *Program) End() int {
+	if len(p.Statements) == 0 {
+		return 0
+	}
+	return endOf(p.Statements[len(p.Statements)-1], 0)
+}
+
+// Statements
+
+func (ls *LetStatement) Pos() int { return ls.Token.Pos }
+func (ls *LetStatement) End() int {
+	return endOf(ls.Value, endOf(ls.Pattern, endOf(ls.Name, ls.Token.End)))
+}
+
+func (rs *ReturnStatement) Pos() int { return rs.Token.Pos }
+func (rs *ReturnStatement) End() int {
+	return endOf(rs.ReturnValue, rs.Token.End)
+}
+
+func (es *ExpressionStatement) Pos() int { return es.Token.Pos }
+func (es *ExpressionStatement) End() int {
+	return endOf(es.Expression, es.Token.End)
+}
+
+func (bs *BlockStatement) Pos() int { return bs.Token.Pos }
+func (bs *BlockStatement) End() int {
+	if bs.Rbrace.Type == "" {
+		if len(bs.Statements) > 0 {
+			return endOf(bs.Statements[len(bs.Statements)-1], bs.Token.End)
+		}
+		return bs.Token.End
+	}
+	return bs.Rbrace.End
+}
+
+func (fs *FunctionStatement) Pos() int { return fs.Token.Pos }
+func (fs *FunctionStatement) End() int {
+	return endOf(fs.Function, endOf(fs.Name, fs.Token.End))
+}
+
+func (is *InfixStatement) Pos() int { return is.Token.Pos }
+func (is *InfixStatement) End() int {
+	return endOf(is.Function, is.Token.End)
+}
+
+func (ws *WhileStatement) Pos() int { return ws.Token.Pos }
+func (ws *WhileStatement) End() int {
+	return endOf(ws.Body, endOf(ws.Condition, ws.Token.End))
+}
+
+func (fs *ForStatement) Pos() int { return fs.Token.Pos }
+func (fs *ForStatement) End() int {
+	return endOf(fs.Body, fs.Token.End)
+}
+
+func (ss *StructStatement) Pos() int { return ss.Token.Pos }
+func (ss *StructStatement) End() int {
+	if ss.Rbrace.Type == "" {
+		return ss.Token.End
+	}
+	return ss.Rbrace.End
+}
+
+func (bs *BreakStatement) Pos() int { return bs.Token.Pos }
+func (bs *BreakStatement) End() int { return bs.Token.End }
+
+func (cs *ContinueStatement) Pos() int { return cs.Token.Pos }
+func (cs *ContinueStatement) End() int { return cs.Token.End }
+
+// Expressions
+
+func (i *Identifier) Pos() int { return i.Token.Pos }
+func (i *Identifier) End() int { return i.Token.End }
+
+func (il *IntegerLiteral) Pos() int { return il.Token.Pos }
+func (il *IntegerLiteral) End() int { return il.Token.End }
+
+func (sl *StringLiteral) Pos() int { return sl.Token.Pos }
+func (sl *StringLiteral) End() int { return sl.Token.End }
+
+func (b *Boolean) Pos() int { return b.Token.Pos }
+func (b *Boolean) End() int { return b.Token.End }
+
+func (nl *NullLiteral) Pos() int { return nl.Token.Pos }
+func (nl *NullLiteral) End() int { return nl.Token.End }
+
+func (pe *PrefixExpression) Pos() int { return pe.Token.Pos }
+func (pe *PrefixExpression) End() int {
+	return endOf(pe.Right, pe.Token.End)
+}
+
+func (oe *InfixExpression) Pos() int {
+	return posOf(oe.Left, oe.Token.Pos)
+}
+func (oe *InfixExpression) End() int {
+	return endOf(oe.Right, oe.Token.End)
+}
+
+func (ie *IfExpression) Pos() int { return ie.Token.Pos }
+func (ie *IfExpression) End() int {
+	return endOf(ie.Alternative, endOf(ie.Consequence, endOf(ie.Condition, ie.Token.End)))
+}
+
+func (fl *FunctionLiteral) Pos() int { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() int {
+	return endOf(fl.Body, fl.Token.End)
+}
+
+func (ml *MacroLiteral) Pos() int { return ml.Token.Pos }
+func (ml *MacroLiteral) End() int {
+	return endOf(ml.Body, ml.Token.End)
+}
+
+func (ce *CallExpression) Pos() int {
+	return posOf(ce.Function, ce.Token.Pos)
+}
+func (ce *CallExpression) End() int {
+	if ce.Rparen.Type == "" {
+		return ce.Token.End
+	}
+	return ce.Rparen.End
+}
+
+func (ae *AssignExpression) Pos() int {
+	return posOf(ae.Target, ae.Token.Pos)
+}
+func (ae *AssignExpression) End() int {
+	return endOf(ae.Value, ae.Token.End)
+}
+
+func (ie *IndexExpression) Pos() int {
+	return posOf(ie.Left, ie.Token.Pos)
+}
+func (ie *IndexExpression) End() int {
+	if ie.Rbracket.Type == "" {
+		return endOf(ie.Index, ie.Token.End)
+	}
+	return ie.Rbracket.End
+}
+
+func (me *MemberExpression) Pos() int {
+	return posOf(me.Object, me.Token.Pos)
+}
+func (me *MemberExpression) End() int {
+	return endOf(me.Property, me.Token.End)
+}
+
+func (me *MatchExpression) Pos() int { return me.Token.Pos }
+func (me *MatchExpression) End() int {
+	if me.Rbrace.Type == "" {
+		if len(me.Arms) > 0 {
+			return endOf(me.Arms[len(me.Arms)-1], me.Token.End)
+		}
+		return endOf(me.Subject, me.Token.End)
+	}
+	return me.Rbrace.End
+}
+
+func (ma *MatchArm) Pos() int {
+	return posOf(ma.Pattern, ma.Token.Pos)
+}
+func (ma *MatchArm) End() int {
+	return endOf(ma.Body, ma.Token.End)
+}
+
+func (wp *WildcardPattern) Pos() int { return wp.Token.Pos }
+func (wp *WildcardPattern) End() int { return wp.Token.End }
+
+func (bp *BindingPattern) Pos() int { return posOf(bp.Name, 0) }
+func (bp *BindingPattern) End() int { return endOf(bp.Name, 0) }
+
+func (lp *LiteralPattern) Pos() int { return posOf(lp.Value, 0) }
+func (lp *LiteralPattern) End() int { return endOf(lp.Value, 0) }
+
+func (ap *ArrayPattern) Pos() int { return ap.Token.Pos }
+func (ap *ArrayPattern) End() int {
+	if ap.Rbracket.Type == "" {
+		return ap.Token.End
+	}
+	return ap.Rbracket.End
+}
+
+func (hp *HashPattern) Pos() int { return hp.Token.Pos }
+func (hp *HashPattern) End() int {
+	if hp.Rbrace.Type == "" {
+		return hp.Token.End
+	}
+	return hp.Rbrace.End
+}
+
+func (pe *ParenExpression) Pos() int { return pe.Token.Pos }
+func (pe *ParenExpression) End() int {
+	if pe.Rparen.Type == "" {
+		return endOf(pe.Expression, pe.Token.End)
+	}
+	return pe.Rparen.End
+}
@@ -0,0 +1,119 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1 + y;", []string{"*ast.Program", "*ast.LetStatement", "x", "*ast.InfixExpression", "1", "y"}},
+		{"return -a;", []string{"*ast.Program", "*ast.ReturnStatement", "*ast.PrefixExpression", "a"}},
+		{`add(1, "s", true)`, []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.CallExpression", "add", "1", "s", "true"}},
+		{"if (a) { b } else { c }", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.IfExpression", "a",
+			"*ast.BlockStatement", "*ast.ExpressionStatement", "b", "*ast.BlockStatement", "*ast.ExpressionStatement", "c"}},
+		{"fn(a, b = 2, ...c) { a }", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.FunctionLiteral",
+			"a", "b", "2", "c", "*ast.BlockStatement", "*ast.ExpressionStatement", "a"}},
+		{"fn f(a) { a }", []string{"*ast.Program", "*ast.FunctionStatement", "f", "*ast.FunctionLiteral",
+			"a", "*ast.BlockStatement", "*ast.ExpressionStatement", "a"}},
+		{"macro(a, b) { quote(a) }", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.MacroLiteral",
+			"a", "b", "*ast.BlockStatement", "*ast.ExpressionStatement", "*ast.CallExpression", "quote", "a"}},
+		{"a?.b?[null]", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.IndexExpression", "*ast.MemberExpression", "a", "b", "*ast.NullLiteral"}},
+		{`match (x) { [a, ...r] if a => 1, {"k": _} => -2 }`, []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.MatchExpression", "x",
+			"*ast.MatchArm", "*ast.ArrayPattern", "*ast.BindingPattern", "a", "r", "a", "1",
+			"*ast.MatchArm", "*ast.HashPattern", "k", "*ast.WildcardPattern", "*ast.PrefixExpression", "2"}},
+		{"let {a, b: [c]} = x;", []string{"*ast.Program", "*ast.LetStatement", "*ast.HashPattern", "a", "*ast.BindingPattern", "a",
+			"b", "*ast.ArrayPattern", "*ast.BindingPattern", "c", "x"}},
+		{"struct P { x, y } p.x = 1", []string{"*ast.Program", "*ast.StructStatement", "P", "x", "y",
+			"*ast.ExpressionStatement", "*ast.AssignExpression", "*ast.MemberExpression", "p", "x", "1"}},
+		{"infix 5 left <+> = f;", []string{"*ast.Program", "*ast.InfixStatement", "f"}},
+		{"while (a) { break; }", []string{"*ast.Program", "*ast.WhileStatement", "a", "*ast.BlockStatement", "*ast.BreakStatement"}},
+		{"for (;;) { continue; }", []string{"*ast.Program", "*ast.ForStatement", "*ast.BlockStatement", "*ast.ContinueStatement"}},
+		{"for (let i = 0; i < n; i += 1) {}", []string{"*ast.Program", "*ast.ForStatement", "*ast.LetStatement", "i", "0",
+			"*ast.InfixExpression", "i", "n", "*ast.AssignExpression", "i", "1", "*ast.BlockStatement"}},
+		{"(a + b) * c", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.InfixExpression", "*ast.ParenExpression",
+			"*ast.InfixExpression", "a", "b", "c"}},
+		{"a[0] = b", []string{"*ast.Program", "*ast.ExpressionStatement", "*ast.AssignExpression", "*ast.IndexExpression", "a", "0", "b"}},
+	}
+
+	for _, tt := range tests {
+		var visited []string
+		ast.Inspect(parse(t, tt.input), func(node ast.Node) bool {
+			switch node := node.(type) {
+			case nil:
+			case *ast.Identifier, *ast.IntegerLiteral, *ast.Boolean:
+				visited = append(visited, node.String())
+			case *ast.StringLiteral:
+				visited = append(visited, node.Value)
+			default:
+				visited = append(visited, fmt.Sprintf("%T", node))
+			}
+			return true
+		})
+		if strings.Join(visited, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("wrong nodes visited for %q.\nwant = %q\ngot  = %q", tt.input, tt.expected, visited)
+		}
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, "let f = fn(x) { x + 1 }; f(2 * 3)")
+	var visited []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		visited = append(visited, fmt.Sprintf("%T", node))
+		_, isFunction := node.(*ast.FunctionLiteral)
+		_, isCall := node.(*ast.CallExpression)
+		return !isFunction && !isCall
+	})
+	expected := "*ast.Program *ast.LetStatement *ast.Identifier *ast.FunctionLiteral *ast.ExpressionStatement *ast.CallExpression"
+	if actual := strings.Join(visited, " "); actual != expected {
+		t.Errorf("wrong nodes visited.\nwant = %q\ngot  = %q", expected, actual)
+	}
+}
+
+// depthVisitor records the depth of every node it visits
+type depthVisitor struct {
+	depth  int
+	depths *[]int
+}
+
+func (v depthVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*v.depths = append(*v.depths, -v.depth)
+		return nil
+	}
+	*v.depths = append(*v.depths, v.depth)
+	return depthVisitor{v.depth + 1, v.depths}
+}
+
+func TestWalk(t *testing.T) {
+	var depths []int
+	ast.Walk(depthVisitor{0, &depths}, parse(t, "-a + b"))
+	// Program, ExpressionStatement, InfixExpression, PrefixExpression and a, each followed
+	// by the nil that closes its children
+	expected := []int{0, 1, 2, 3, 4, -5, -4, 3, -4, -3, -2, -1}
+	if fmt.Sprint(depths) != fmt.Sprint(expected) {
+		t.Errorf("wrong depths. want = %v, got = %v", expected, depths)
+	}
+}
@@ -0,0 +1,291 @@
+// Package astviz renders syntax trees for people: as Graphviz DOT graphs and as
+// Lisp-style S-expressions. Both show the structure of the tree, including the
+// grouping that precedence produced, which the String methods of the nodes only
+// hint at with parentheses.
+package astviz
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BentleyOph/monke/ast"
+)
+
+// Dot renders the tree rooted at node as a Graphviz digraph, with one box per node
+// labelled with its kind and, where it has one, its operator, name or value:
+//
+//	monke parse --format=dot file.mk | dot -Tsvg > ast.svg
+func Dot(node ast.Node) string {
+	var out bytes.Buffer
+	out.WriteString("digraph AST {\n")
+	out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	// parents holds the ids of the nodes whose children are being visited
+	parents := []int{}
+	next := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+		id := next
+		next++
+		fmt.Fprintf(&out, "\tn%d [label=\"%s\"];\n", id, dotLabel(n))
+		if len(parents) > 0 {
+			fmt.Fprintf(&out, "\tn%d -> n%d;\n", parents[len(parents)-1], id)
+		}
+		parents = append(parents, id)
+		return true
+	})
+
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// dotLabel returns the escaped label of n: its kind, followed by its details on a second line
+func dotLabel(n ast.Node) string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+	var detail string
+	switch n := n.(type) {
+	case *ast.Identifier:
+		detail = n.Value
+	case *ast.IntegerLiteral:
+		detail = n.Token.Literal
+	case *ast.StringLiteral:
+		detail = strconv.Quote(n.Value)
+	case *ast.Boolean:
+		detail = strconv.FormatBool(n.Value)
+	case *ast.PrefixExpression:
+		detail = n.Operator
+	case *ast.InfixExpression:
+		detail = n.Operator
+	case *ast.AssignExpression:
+		detail = n.Operator
+	case *ast.IndexExpression:
+		if n.Optional {
+			detail = "optional"
+		}
+	case *ast.MemberExpression:
+		if n.Optional {
+			detail = "optional"
+		}
+	case *ast.InfixStatement:
+		detail = fmt.Sprintf("%s %d %s", n.Operator, n.Precedence, n.Associativity)
+	case *ast.FunctionLiteral:
+		detail = n.Name
+		if n.Rest != nil {
+			detail = strings.TrimSpace(detail + " ..." + n.Rest.Value)
+		}
+	case *ast.ArrayPattern:
+		if n.Rest != nil {
+			detail = "..." + n.Rest.Value
+		}
+	}
+	if detail == "" {
+		return dotEscape(kind)
+	}
+	return dotEscape(kind) + `\n` + dotEscape(detail)
+}
+
+// dotEscape escapes s for use inside a double-quoted DOT string
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// SExpr renders the tree rooted at node as S-expressions, one line per top-level
+// statement, e.g. let x = 1 + 2 * 3; becomes (let x (+ 1 (* 2 3))).
+// Parentheses in the source only group and do not show up; clauses left out of a
+// for loop are written as _. A match arm is (=> pattern guard body), or
+// (=> pattern body) without a guard.
+func SExpr(node ast.Node) string {
+	var out bytes.Buffer
+	writeSExpr(&out, node)
+	return out.String()
+}
+
+func writeSExpr(out *bytes.Buffer, node ast.Node) {
+	// list writes (head item...), rendering nodes as S-expressions and strings as they are
+	list := func(head string, items ...interface{}) {
+		out.WriteString("(" + head)
+		for _, item := range items {
+			out.WriteString(" ")
+			switch item := item.(type) {
+			case string:
+				out.WriteString(item)
+			case ast.Node:
+				writeSExpr(out, item)
+			}
+		}
+		out.WriteString(")")
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for i, s := range n.Statements {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			writeSExpr(out, s)
+		}
+	case *ast.LetStatement:
+		if n.Pattern != nil {
+			list("let", n.Pattern, n.Value)
+		} else {
+			list("let", n.Name, n.Value)
+		}
+	case *ast.ReturnStatement:
+		list("return", n.ReturnValue)
+	case *ast.ExpressionStatement:
+		writeSExpr(out, n.Expression)
+	case *ast.BlockStatement:
+		list("block", statementItems(n.Statements)...)
+	case *ast.FunctionStatement:
+		list("fn", append([]interface{}{n.Name}, functionItems(n.Function)...)...)
+	case *ast.StructStatement:
+		items := []interface{}{n.Name}
+		for _, f := range n.Fields {
+			items = append(items, f)
+		}
+		list("struct", items...)
+	case *ast.InfixStatement:
+		list("infix", n.Operator, strconv.Itoa(n.Precedence), n.Associativity, n.Function)
+	case *ast.WhileStatement:
+		list("while", n.Condition, n.Body)
+	case *ast.ForStatement:
+		items := []interface{}{"_", "_", "_", n.Body}
+		if n.Init != nil {
+			items[0] = n.Init
+		}
+		if n.Condition != nil {
+			items[1] = n.Condition
+		}
+		if n.Post != nil {
+			items[2] = n.Post
+		}
+		list("for", items...)
+	case *ast.BreakStatement:
+		list("break")
+	case *ast.ContinueStatement:
+		list("continue")
+	case *ast.Identifier:
+		out.WriteString(n.Value)
+	case *ast.IntegerLiteral:
+		out.WriteString(n.Token.Literal)
+	case *ast.StringLiteral:
+		out.WriteString(strconv.Quote(n.Value))
+	case *ast.Boolean:
+		out.WriteString(strconv.FormatBool(n.Value))
+	case *ast.NullLiteral:
+		out.WriteString("null")
+	case *ast.PrefixExpression:
+		list(n.Operator, n.Right)
+	case *ast.InfixExpression:
+		list(n.Operator, n.Left, n.Right)
+	case *ast.IfExpression:
+		if n.Alternative != nil {
+			list("if", n.Condition, n.Consequence, n.Alternative)
+		} else {
+			list("if", n.Condition, n.Consequence)
+		}
+	case *ast.FunctionLiteral:
+		list("fn", functionItems(n)...)
+	case *ast.MacroLiteral:
+		params := make([]string, len(n.Parameters))
+		for i, param := range n.Parameters {
+			params[i] = param.Value
+		}
+		list("macro", "("+strings.Join(params, " ")+")", n.Body)
+	case *ast.CallExpression:
+		items := []interface{}{n.Function}
+		for _, arg := range n.Arguments {
+			items = append(items, arg)
+		}
+		list("call", items...)
+	case *ast.AssignExpression:
+		list(n.Operator, n.Target, n.Value)
+	case *ast.IndexExpression:
+		if n.Optional {
+			list("?index", n.Left, n.Index)
+		} else {
+			list("index", n.Left, n.Index)
+		}
+	case *ast.MemberExpression:
+		if n.Optional {
+			list("?.", n.Object, n.Property)
+		} else {
+			list(".", n.Object, n.Property)
+		}
+	case *ast.ParenExpression:
+		writeSExpr(out, n.Expression)
+	case *ast.MatchExpression:
+		items := []interface{}{n.Subject}
+		for _, arm := range n.Arms {
+			items = append(items, arm)
+		}
+		list("match", items...)
+	case *ast.MatchArm:
+		if n.Guard != nil {
+			list("=>", n.Pattern, n.Guard, n.Body)
+		} else {
+			list("=>", n.Pattern, n.Body)
+		}
+	case *ast.WildcardPattern:
+		out.WriteString("_")
+	case *ast.BindingPattern:
+		writeSExpr(out, n.Name)
+	case *ast.LiteralPattern:
+		writeSExpr(out, n.Value)
+	case *ast.ArrayPattern:
+		items := []interface{}{}
+		for _, e := range n.Elements {
+			items = append(items, e)
+		}
+		if n.Rest != nil {
+			items = append(items, "..."+n.Rest.Value)
+		}
+		list("array", items...)
+	case *ast.HashPattern:
+		items := []interface{}{}
+		for _, pair := range n.Pairs {
+			items = append(items, pair.Key, pair.Value)
+		}
+		list("hash", items...)
+	default:
+		fmt.Fprintf(out, "(%T)", node)
+	}
+}
+
+func statementItems(statements []ast.Statement) []interface{} {
+	items := []interface{}{}
+	for _, s := range statements {
+		items = append(items, s)
+	}
+	return items
+}
+
+// functionItems returns the parameter list and body of fl; a parameter with a default
+// is written as (= name default) and the variadic one as ...name
+func functionItems(fl *ast.FunctionLiteral) []interface{} {
+	var params bytes.Buffer
+	params.WriteString("(")
+	for i, param := range fl.Parameters {
+		if i > 0 {
+			params.WriteString(" ")
+		}
+		if value := fl.Default(i); value != nil {
+			params.WriteString("(= " + param.Value + " " + SExpr(value) + ")")
+		} else {
+			params.WriteString(param.Value)
+		}
+	}
+	if fl.Rest != nil {
+		if len(fl.Parameters) > 0 {
+			params.WriteString(" ")
+		}
+		params.WriteString("..." + fl.Rest.Value)
+	}
+	params.WriteString(")")
+	return []interface{}{params.String(), fl.Body}
+}
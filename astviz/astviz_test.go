@@ -0,0 +1,80 @@
+package astviz
+
+import (
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestSExpr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1 + 2 * 3;", "(let x (+ 1 (* 2 3)))"},
+		{"(1 + 2) * 3", "(* (+ 1 2) 3)"},
+		{"2 ** 3 ** 2", "(** 2 (** 3 2))"},
+		{"return -a;", "(return (- a))"},
+		{`let s = "a b"; !true`, "(let s \"a b\")\n(! true)"},
+		{"if (a < b) { a } else { b; c }", "(if (< a b) (block a) (block b c))"},
+		{"if (a) {}", "(if a (block))"},
+		{"let f = fn(a, b = 2, ...rest) { a };", "(let f (fn (a (= b 2) ...rest) (block a)))"},
+		{"let m = macro(a, b) { quote(a) };", "(let m (macro (a b) (block (call quote a))))"},
+		{"fn f() { return 1; }", "(fn f () (block (return 1)))"},
+		{"f(1, g(2))[0]", "(index (call f 1 (call g 2)) 0)"},
+		{"a?.b?[0] ?? null", "(?? (?index (?. a b) 0) null)"},
+		{`match (x) { [a, ...r] if a > 0 => a, {"k": -1} => 0, _ => null }`,
+			`(match x (=> (array a ...r) (> a 0) a) (=> (hash "k" (- 1)) 0) (=> _ null))`},
+		{"let [a, ...r] = xs; let {name, age: [y]} = p;", "(let (array a ...r) xs)\n(let (hash name name age (array y)) p)"},
+		{"struct Point { x, y } p.x = p.y", "(struct Point x y)\n(= (. p x) (. p y))"},
+		{"struct Unit {}", "(struct Unit)"},
+		{"while (x) { x -= 1; break; }", "(while x (block (-= x 1) (break)))"},
+		{"for (let i = 0; i < n; i += 1) { continue; }", "(for (let i 0) (< i n) (+= i 1) (block (continue)))"},
+		{"for (;;) {}", "(for _ _ _ (block))"},
+		{"infix 6 right <+> = add;", "(infix <+> 6 right add)"},
+		{"a[0] = b = c", "(= (index a 0) (= b c))"},
+	}
+
+	for _, tt := range tests {
+		if actual := SExpr(parse(t, tt.input)); actual != tt.expected {
+			t.Errorf("SExpr(%q) wrong.\nwant = %q\ngot  = %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestDot(t *testing.T) {
+	expected := `digraph AST {
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program"];
+	n1 [label="LetStatement"];
+	n0 -> n1;
+	n2 [label="Identifier\nx"];
+	n1 -> n2;
+	n3 [label="InfixExpression\n+"];
+	n1 -> n3;
+	n4 [label="IntegerLiteral\n1"];
+	n3 -> n4;
+	n5 [label="CallExpression"];
+	n3 -> n5;
+	n6 [label="Identifier\nf"];
+	n5 -> n6;
+	n7 [label="StringLiteral\n\"hi there\""];
+	n5 -> n7;
+}
+`
+	if actual := Dot(parse(t, `let x = 1 + f("hi there");`)); actual != expected {
+		t.Errorf("Dot wrong.\nwant = %s\ngot  = %s", expected, actual)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/BentleyOph/monke/doc"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+// runDoc implements `monke doc [--format=markdown|html] [file.mk]`: it prints a reference
+// page for the top-level bindings of the file, or of stdin without one, and their ///
+// doc comments. It returns the exit code.
+func runDoc(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "markdown", "output format: markdown or html")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monke doc [--format=markdown|html] [file.mk]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	switch *format {
+	case "markdown", "html":
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		flags.Usage()
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	filename, source, err := readSource(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s: %s\n", filename, msg)
+		}
+		return 1
+	}
+
+	bindings := doc.Bindings(program)
+	title := filepath.Base(filename)
+	if *format == "html" {
+		fmt.Fprint(stdout, doc.HTML(title, bindings))
+	} else {
+		fmt.Fprint(stdout, doc.Markdown(title, bindings))
+	}
+	return 0
+}
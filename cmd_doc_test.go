@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDoc(t *testing.T) {
+	input := "/// Adds.\nfn add(a, b) { a + b }\nlet x = 1;"
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "# <stdin>\n\n## `add(a, b)`\n\nAdds.\n\n## `x`\n"},
+		{[]string{"--format=markdown"}, "# <stdin>\n\n## `add(a, b)`\n\nAdds.\n\n## `x`\n"},
+		{
+			[]string{"--format=html"},
+			"<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>&lt;stdin&gt;</title>\n</head>\n<body>\n" +
+				"<h1>&lt;stdin&gt;</h1>\n<h2 id=\"add\"><code>add(a, b)</code></h2>\n<p>Adds.</p>\n" +
+				"<h2 id=\"x\"><code>x</code></h2>\n</body>\n</html>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runDoc(tt.args, strings.NewReader(input), &stdout, &stderr); code != 0 {
+			t.Errorf("runDoc(%q) exited with %d. stderr = %q", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("runDoc(%q) wrong output.\nwant = %q\ngot  = %q", tt.args, tt.expected, stdout.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("runDoc(%q) wrote to stderr: %q", tt.args, stderr.String())
+		}
+	}
+}
+
+func TestRunDocFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "util.mk")
+	if err := os.WriteFile(filename, []byte("let x = 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := runDoc([]string{filename}, strings.NewReader("ignored"), &stdout, &stderr); code != 0 {
+		t.Fatalf("runDoc exited with %d. stderr = %q", code, stderr.String())
+	}
+	if expected := "# util.mk\n\n## `x`\n"; stdout.String() != expected {
+		t.Errorf("runDoc printed %q. want = %q", stdout.String(), expected)
+	}
+}
+
+func TestRunDocErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stderr string // what stderr starts with
+	}{
+		{nil, "let = 1", 1, "<stdin>: expected next token to be IDENT, got = instead\n<stdin>: no prefix parse function for = found\n"},
+		{[]string{"--format=pdf"}, "x", 2, "unknown format \"pdf\"\nusage: monke doc"},
+		{[]string{"--nope"}, "x", 2, "flag provided but not defined: -nope\nusage: monke doc"},
+		{[]string{"a.mk", "b.mk"}, "x", 2, "usage: monke doc"},
+		{[]string{filepath.Join("testdata", "missing.mk")}, "x", 1, "open testdata/missing.mk: no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runDoc(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.code {
+			t.Errorf("runDoc(%q) exited with %d. want = %d", tt.args, code, tt.code)
+		}
+		if !strings.HasPrefix(stderr.String(), tt.stderr) {
+			t.Errorf("runDoc(%q) wrong stderr.\nwant prefix = %q\ngot         = %q", tt.args, tt.stderr, stderr.String())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("runDoc(%q) printed %q despite failing", tt.args, stdout.String())
+		}
+	}
+}
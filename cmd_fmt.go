@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BentleyOph/monke/format"
+)
+
+// runFmt implements `monke fmt [-w] [-d] [-l] [-width n] files...`: it formats each
+// file and prints the result, writes it back with -w, prints what would change with -d
+// or lists the files that would change with -l. Without files it formats stdin to
+// stdout. It returns the exit code.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the result")
+	list := flags.Bool("l", false, "list the files whose formatting differs instead of printing the result")
+	width := flags.Int("width", format.DefaultWidth, "line width to break long lines at")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monke fmt [-w] [-d] [-l] [-width n] [files...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	config := format.Config{Width: *width}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "cannot use -w with standard input")
+			return 2
+		}
+		source, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return formatSource("<stdin>", string(source), config, *diff, *list, stdout, stderr)
+	}
+
+	code := 0
+	for _, filename := range flags.Args() {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		if !*write {
+			if formatSource(filename, string(source), config, *diff, *list, stdout, stderr) != 0 {
+				code = 1
+			}
+			continue
+		}
+
+		formatted, err := formatOrReport(filename, string(source), config, stderr)
+		if err != nil {
+			code = 1
+			continue
+		}
+		if *list && formatted != string(source) {
+			fmt.Fprintln(stdout, filename)
+		}
+		if *diff {
+			fmt.Fprint(stdout, unifiedDiff(filename, string(source), formatted))
+		}
+		if formatted != string(source) {
+			info, err := os.Stat(filename)
+			if err == nil {
+				err = os.WriteFile(filename, []byte(formatted), info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				code = 1
+			}
+		}
+	}
+	return code
+}
+
+// formatSource prints the formatted source, or instead the diff to it when diff is set
+// and the filename when list is set and the formatting differs
+func formatSource(filename, source string, config format.Config, diff, list bool, stdout, stderr io.Writer) int {
+	formatted, err := formatOrReport(filename, source, config, stderr)
+	if err != nil {
+		return 1
+	}
+	if list && formatted != source {
+		fmt.Fprintln(stdout, filename)
+	}
+	if diff {
+		fmt.Fprint(stdout, unifiedDiff(filename, source, formatted))
+	} else if !list {
+		fmt.Fprint(stdout, formatted)
+	}
+	return 0
+}
+
+// formatOrReport formats source and prints its parse errors as "file: msg"
+func formatOrReport(filename, source string, config format.Config, stderr io.Writer) (string, error) {
+	formatted, err := config.Source(source)
+	var errs format.ErrorList
+	if errors.As(err, &errs) {
+		for _, msg := range errs {
+			fmt.Fprintf(stderr, "%s: %s\n", filename, msg)
+		}
+	}
+	return formatted, err
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunFmt(t *testing.T) {
+	// $DIR in args, files and output stands for a temporary directory holding files
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		files  map[string]string // file contents before running
+		after  map[string]string // file contents after running
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "stdin", stdin: "let x=1", stdout: "let x = 1;\n"},
+		{name: "stdin width", args: []string{"-width", "10"}, stdin: "f(aaaa, bbbb)", stdout: "f(\n\taaaa,\n\tbbbb\n)\n"},
+		{
+			name: "stdin diff", args: []string{"-d"}, stdin: "let x=1\n",
+			stdout: "--- <stdin>\n+++ <stdin>\n@@ -1,1 +1,1 @@\n-let x=1\n+let x = 1;\n",
+		},
+		{name: "stdin diff formatted", args: []string{"-d"}, stdin: "let x = 1;\n"},
+		{name: "stdin list", args: []string{"-l"}, stdin: "let x=1", stdout: "<stdin>\n"},
+		{name: "stdin list formatted", args: []string{"-l"}, stdin: "let x = 1;\n"},
+		{name: "stdin write", args: []string{"-w"}, code: 2, stderr: "cannot use -w with standard input\n"},
+		{
+			name: "stdin parse error", stdin: "let = 1", code: 1,
+			stderr: "<stdin>: expected next token to be IDENT, got = instead\n<stdin>: no prefix parse function for = found\n",
+		},
+		{
+			name: "file", args: []string{"$DIR/a.mk"},
+			files:  map[string]string{"a.mk": "let x=1"},
+			after:  map[string]string{"a.mk": "let x=1"},
+			stdout: "let x = 1;\n",
+		},
+		{
+			name: "write", args: []string{"-w", "$DIR/a.mk", "$DIR/b.mk"},
+			files: map[string]string{"a.mk": "let x=1", "b.mk": "y\n"},
+			after: map[string]string{"a.mk": "let x = 1;\n", "b.mk": "y\n"},
+		},
+		{
+			name: "write and list", args: []string{"-w", "-l", "$DIR/a.mk", "$DIR/b.mk"},
+			files:  map[string]string{"a.mk": "let x=1", "b.mk": "y\n"},
+			after:  map[string]string{"a.mk": "let x = 1;\n", "b.mk": "y\n"},
+			stdout: "$DIR/a.mk\n",
+		},
+		{
+			name: "write and diff", args: []string{"-w", "-d", "$DIR/a.mk"},
+			files:  map[string]string{"a.mk": "x\nlet x=1\n"},
+			after:  map[string]string{"a.mk": "x\nlet x = 1;\n"},
+			stdout: "--- $DIR/a.mk\n+++ $DIR/a.mk\n@@ -1,2 +1,2 @@\n x\n-let x=1\n+let x = 1;\n",
+		},
+		{
+			name: "list", args: []string{"-l", "$DIR/a.mk", "$DIR/b.mk"},
+			files:  map[string]string{"a.mk": "y\n", "b.mk": "y;"},
+			after:  map[string]string{"a.mk": "y\n", "b.mk": "y;"},
+			stdout: "$DIR/b.mk\n",
+		},
+		{
+			name: "write keeps going after a parse error", args: []string{"-w", "$DIR/a.mk", "$DIR/b.mk"},
+			files:  map[string]string{"a.mk": "let = 1", "b.mk": "y;"},
+			after:  map[string]string{"a.mk": "let = 1", "b.mk": "y\n"},
+			code:   1,
+			stderr: "$DIR/a.mk: expected next token to be IDENT, got = instead\n$DIR/a.mk: no prefix parse function for = found\n",
+		},
+		{
+			name: "missing file", args: []string{"$DIR/missing.mk"}, code: 1,
+			stderr: "open $DIR/missing.mk: no such file or directory\n",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		args := make([]string, len(tt.args))
+		for i, arg := range tt.args {
+			args[i] = strings.ReplaceAll(arg, "$DIR", dir)
+		}
+
+		var stdout, stderr bytes.Buffer
+		code := runFmt(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%s: wrong exit code. want = %d, got = %d (stderr %q)", tt.name, tt.code, code, stderr.String())
+		}
+		if want := strings.ReplaceAll(tt.stdout, "$DIR", dir); stdout.String() != want {
+			t.Errorf("%s: wrong stdout.\nwant = %q\ngot  = %q", tt.name, want, stdout.String())
+		}
+		if want := strings.ReplaceAll(tt.stderr, "$DIR", dir); stderr.String() != want {
+			t.Errorf("%s: wrong stderr.\nwant = %q\ngot  = %q", tt.name, want, stderr.String())
+		}
+		for name, want := range tt.after {
+			got, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("%s: wrong content of %s.\nwant = %q\ngot  = %q", tt.name, name, want, got)
+			}
+		}
+	}
+}
+
+func TestRunFmtUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runFmt([]string{"-x"}, strings.NewReader(""), &stdout, &stderr); code != 2 {
+		t.Errorf("wrong exit code for an unknown flag. want = 2, got = %d", code)
+	}
+	if !strings.Contains(stderr.String(), "usage: monke fmt") {
+		t.Errorf("expected usage on stderr. got = %q", stderr.String())
+	}
+}
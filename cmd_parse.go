@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/astviz"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+// runParse implements `monke parse [--format=text|json|dot|sexpr] [file.mk]`: it parses
+// the file, or stdin without one, and prints the resulting AST. It returns the exit code.
+func runParse(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text, json, dot (Graphviz) or sexpr")
+	asJSON := flags.Bool("json", false, "shorthand for --format=json")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monke parse [--format=text|json|dot|sexpr] [--json] [file.mk]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *asJSON {
+		*format = "json"
+	}
+	switch *format {
+	case "text", "json", "dot", "sexpr":
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		flags.Usage()
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	filename, source, err := readSource(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s: %s\n", filename, msg)
+		}
+		return 1
+	}
+
+	switch *format {
+	case "json":
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s\n", data)
+	case "dot":
+		fmt.Fprint(stdout, astviz.Dot(program))
+	case "sexpr":
+		fmt.Fprintln(stdout, astviz.SExpr(program))
+	default:
+		fmt.Fprintln(stdout, program.String())
+	}
+	return 0
+}
+
+// readSource reads the file named by the first of args, or stdin when args is empty
+func readSource(args []string, stdin io.Reader) (filename, source string, err error) {
+	if len(args) == 0 {
+		data, err := io.ReadAll(stdin)
+		return "<stdin>", string(data), err
+	}
+	data, err := os.ReadFile(args[0])
+	return args[0], string(data), err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/astviz"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+func TestRunParse(t *testing.T) {
+	input := "let x = 1 + 2;"
+	program := parser.New(lexer.New(input)).ParseProgram()
+	json, err := ast.MarshalJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{nil, "let x = (1 + 2);\n"},
+		{[]string{"--format=text"}, "let x = (1 + 2);\n"},
+		{[]string{"--format=sexpr"}, "(let x (+ 1 2))\n"},
+		{[]string{"--format=dot"}, astviz.Dot(program)},
+		{[]string{"--format=json"}, string(json) + "\n"},
+		{[]string{"--json"}, string(json) + "\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runParse(tt.args, strings.NewReader(input), &stdout, &stderr); code != 0 {
+			t.Errorf("runParse(%q) exited with %d. stderr = %q", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("runParse(%q) wrong output.\nwant = %q\ngot  = %q", tt.args, tt.expected, stdout.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("runParse(%q) wrote to stderr: %q", tt.args, stderr.String())
+		}
+	}
+}
+
+func TestRunParseFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.mk")
+	if err := os.WriteFile(filename, []byte("f(x)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := runParse([]string{filename}, strings.NewReader("ignored"), &stdout, &stderr); code != 0 {
+		t.Fatalf("runParse exited with %d. stderr = %q", code, stderr.String())
+	}
+	if stdout.String() != "f(x)\n" {
+		t.Errorf("runParse printed %q. want = %q", stdout.String(), "f(x)\n")
+	}
+}
+
+func TestRunParseErrors(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stderr string // what stderr starts with
+	}{
+		{nil, "let = 1", 1, "<stdin>: expected next token to be IDENT, got = instead\n<stdin>: no prefix parse function for = found\n"},
+		{[]string{"--format=yaml"}, "x", 2, "unknown format \"yaml\"\nusage: monke parse"},
+		{[]string{"--nope"}, "x", 2, "flag provided but not defined: -nope\nusage: monke parse"},
+		{[]string{"a.mk", "b.mk"}, "x", 2, "usage: monke parse"},
+		{[]string{filepath.Join("testdata", "missing.mk")}, "x", 1, "open testdata/missing.mk: no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runParse(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); code != tt.code {
+			t.Errorf("runParse(%q) exited with %d. want = %d", tt.args, code, tt.code)
+		}
+		if !strings.HasPrefix(stderr.String(), tt.stderr) {
+			t.Errorf("runParse(%q) wrong stderr.\nwant prefix = %q\ngot         = %q", tt.args, tt.stderr, stderr.String())
+		}
+		if stdout.Len() != 0 {
+			t.Errorf("runParse(%q) printed %q despite failing", tt.args, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package cst provides a lossless concrete syntax tree for Monke source.
+// Every token is stored with the exact source text it was lexed from and with
+// the whitespace, newlines and comments around it, so that Print(Parse(src)) == src
+// for any input. Tools that rewrite code edit the tokens and print the tree
+// instead of going through ast.Program.String(), which discards the layout.
+package cst
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+	"github.com/BentleyOph/monke/token"
+)
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota // spaces, tabs and lone carriage returns
+	Newline                      // "\n" or "\r\n"
+	Comment                      // a // or /// comment, without its line ending
+)
+
+// Trivia is a piece of source text between two tokens that has no meaning to the parser
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  int // byte offset of Text in the input
+}
+
+// Token is a lexer token together with the trivia around it.
+// A token owns the trivia that follows it up to the end of its line; everything
+// after that belongs to the leading trivia of the next token.
+type Token struct {
+	token.Token
+	Text     string // the exact source text, which differs from Literal for strings
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Statement groups the tokens of one top-level statement with its AST node
+type Statement struct {
+	Node   ast.Statement // nil when the input has parse errors
+	Tokens []*Token
+}
+
+// Tree is the concrete syntax tree of a whole input
+type Tree struct {
+	Statements []*Statement
+	EOF        *Token   // holds the trivia at the end of the input
+	Errors     []string // parser errors; the tree is still lossless when there are any
+}
+
+// Parse builds the concrete syntax tree of input. When the input does not parse,
+// all tokens are kept in a single Statement without an AST node.
+func Parse(input string) *Tree {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	tree := &Tree{Errors: p.Errors()}
+
+	// lex declared operators as the single token the parser saw, from their declaration onwards
+	operators := map[int]string{}
+	if len(tree.Errors) == 0 {
+		for _, s := range program.Statements {
+			if is, ok := s.(*ast.InfixStatement); ok {
+				operators[is.Token.Pos] = is.Operator
+			}
+		}
+	}
+	tokens := tokenize(lexer.New(input), input, operators)
+	tree.EOF = tokens[len(tokens)-1]
+	tokens = tokens[:len(tokens)-1]
+
+	if len(tree.Errors) != 0 {
+		if len(tokens) > 0 {
+			tree.Statements = []*Statement{{Tokens: tokens}}
+		}
+		return tree
+	}
+
+	// statements are contiguous, so each one runs from its first token up to the next statement
+	i := 0
+	for n, s := range program.Statements {
+		stmt := &Statement{Node: s}
+		for i < len(tokens) {
+			if n+1 < len(program.Statements) && tokens[i].Pos >= program.Statements[n+1].Pos() {
+				break
+			}
+			stmt.Tokens = append(stmt.Tokens, tokens[i])
+			i++
+		}
+		tree.Statements = append(tree.Statements, stmt)
+	}
+	return tree
+}
+
+// tokenize lexes the whole input, ending with the EOF token, and attaches trivia to the tokens.
+// operators maps the offset of each infix declaration to the operator it declares, which is
+// registered on l once the declaration has been read up to the operator, as the parser does.
+func tokenize(l *lexer.Lexer, input string, operators map[int]string) []*Token {
+	var tokens []*Token
+	end := 0
+	operator, ahead := "", 0 // the operator being declared, and the tokens left before it
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.DOC {
+			continue // left in the text before the next token, where it becomes a comment
+		}
+		if ahead > 0 {
+			ahead--
+			if ahead == 0 {
+				l.RegisterOperator(operator, token.TokenType(operator))
+			}
+		} else if op, ok := operators[tok.Pos]; ok && tok.Type == token.INFIX {
+			operator, ahead = op, 2 // infix is followed by the precedence and the associativity
+		}
+		trivia := splitTrivia(input[end:tok.Pos], end)
+		if len(tokens) > 0 {
+			prev := tokens[len(tokens)-1]
+			n := 0
+			for n < len(trivia) && trivia[n].Kind != Newline {
+				n++
+			}
+			prev.Trailing, trivia = trivia[:n], trivia[n:]
+		}
+		tokens = append(tokens, &Token{Token: tok, Text: input[tok.Pos:tok.End], Leading: trivia})
+		end = tok.End
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// splitTrivia splits the text between two tokens, which starts at offset pos, into trivia
+func splitTrivia(text string, pos int) []Trivia {
+	var trivia []Trivia
+	for len(text) > 0 {
+		var t Trivia
+		switch {
+		case strings.HasPrefix(text, "//"):
+			n := strings.IndexAny(text, "\r\n")
+			if n < 0 {
+				n = len(text)
+			}
+			t = Trivia{Kind: Comment, Text: text[:n]}
+		case strings.HasPrefix(text, "\r\n"):
+			t = Trivia{Kind: Newline, Text: text[:2]}
+		case text[0] == '\n':
+			t = Trivia{Kind: Newline, Text: text[:1]}
+		default:
+			n := 0
+			for n < len(text) && (text[n] == ' ' || text[n] == '\t' || text[n] == '\r' && !strings.HasPrefix(text[n:], "\r\n")) {
+				n++
+			}
+			if n == 0 { // not produced by the lexer, but keep the tree lossless regardless
+				n = 1
+			}
+			t = Trivia{Kind: Whitespace, Text: text[:n]}
+		}
+		t.Pos = pos
+		trivia = append(trivia, t)
+		text = text[len(t.Text):]
+		pos += len(t.Text)
+	}
+	return trivia
+}
+
+// Tokens returns all tokens of the tree in source order, ending with the EOF token
+func (t *Tree) Tokens() []*Token {
+	var tokens []*Token
+	for _, s := range t.Statements {
+		tokens = append(tokens, s.Tokens...)
+	}
+	return append(tokens, t.EOF)
+}
+
+// Print returns the source text of the tree. For an unmodified tree it is the exact input of Parse.
+func Print(tree *Tree) string {
+	var out bytes.Buffer
+	for _, tok := range tree.Tokens() {
+		for _, t := range tok.Leading {
+			out.WriteString(t.Text)
+		}
+		out.WriteString(tok.Text)
+		for _, t := range tok.Trailing {
+			out.WriteString(t.Text)
+		}
+	}
+	return out.String()
+}
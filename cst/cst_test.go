@@ -0,0 +1,160 @@
+package cst
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BentleyOph/monke/token"
+)
+
+func TestPrintIsLossless(t *testing.T) {
+	tests := []string{
+		"",
+		"   \n\t\n",
+		"// only a comment",
+		"let x = 5;",
+		"let   x=5 ;  // five\n\n\nlet y = x;\n",
+		"// header\n\nlet add = fn(a, b) {\n\t// sum\n\ta + b; // trailing\n};\n\nadd(1, 2)\n",
+		"if (x < y) { x }\r\nelse { y }\r\n// done\r\n",
+		"infix 5 left <+> = add;\nx <+> y <+> z;",
+		`"unterminated string`,
+		"let = ;; ) @ # \x00 ",
+		"while (true) { break; }  ",
+		"/// Adds.\r\n///\nfn add(a, b) { a + b } /// trailing\n////\n",
+		"let [a, ...r] = xs;  // rest\nlet {name, age: years} = p;\n",
+		"/// A point.\nstruct Point {\n\tx, // across\n\ty\n}\np.x = p . y;\n",
+		"match (x) {\n\t[a, ...r] if a > 0 => r, // rest\n\t_ => cfg?.port ?? 80,\n}\n",
+	}
+
+	for _, input := range tests {
+		tree := Parse(input)
+		if actual := Print(tree); actual != input {
+			t.Errorf("Print(Parse(%q)) wrong. got = %q", input, actual)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "// header\nlet x = 5; // five\n  x\n"
+	tree := Parse(input)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", tree.Errors)
+	}
+
+	tokens := tree.Tokens()
+	let := tokens[0]
+	if let.Type != token.LET {
+		t.Fatalf("tokens[0] is not LET. got = %q", let.Type)
+	}
+	expectTrivia(t, "let.Leading", let.Leading, []Trivia{
+		{Comment, "// header", 0},
+		{Newline, "\n", 9},
+	})
+
+	semicolon := tokens[4]
+	if semicolon.Type != token.SEMICOLON {
+		t.Fatalf("tokens[4] is not SEMICOLON. got = %q", semicolon.Type)
+	}
+	expectTrivia(t, "semicolon.Trailing", semicolon.Trailing, []Trivia{
+		{Whitespace, " ", 20},
+		{Comment, "// five", 21},
+	})
+
+	x := tokens[5]
+	expectTrivia(t, "x.Leading", x.Leading, []Trivia{
+		{Newline, "\n", 28},
+		{Whitespace, "  ", 29},
+	})
+	expectTrivia(t, "EOF.Leading", tree.EOF.Leading, []Trivia{
+		{Newline, "\n", 32},
+	})
+}
+
+func expectTrivia(t *testing.T, name string, got, want []Trivia) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s has %d trivia, want %d. got = %+v", name, len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s[%d] wrong. want = %+v, got = %+v", name, i, want[i], got[i])
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	input := "let s = \"a b\";\nreturn s;\nfn(x) { x }(1)"
+	tree := Parse(input)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", tree.Errors)
+	}
+	if len(tree.Statements) != 3 {
+		t.Fatalf("tree.Statements does not contain 3 statements. got = %d", len(tree.Statements))
+	}
+
+	expected := []struct {
+		nodeType string
+		tokens   []string
+	}{
+		{"*ast.LetStatement", []string{"let", "s", "=", `"a b"`, ";"}},
+		{"*ast.ReturnStatement", []string{"return", "s", ";"}},
+		{"*ast.ExpressionStatement", []string{"fn", "(", "x", ")", "{", "x", "}", "(", "1", ")"}},
+	}
+	for i, tt := range expected {
+		stmt := tree.Statements[i]
+		if got := fmt.Sprintf("%T", stmt.Node); got != tt.nodeType {
+			t.Errorf("Statements[%d].Node wrong type. want = %s, got = %s", i, tt.nodeType, got)
+		}
+		if len(stmt.Tokens) != len(tt.tokens) {
+			t.Fatalf("Statements[%d] has %d tokens, want %d", i, len(stmt.Tokens), len(tt.tokens))
+		}
+		for j, text := range tt.tokens {
+			if stmt.Tokens[j].Text != text {
+				t.Errorf("Statements[%d].Tokens[%d] wrong. want = %q, got = %q", i, j, text, stmt.Tokens[j].Text)
+			}
+		}
+	}
+}
+
+func TestParseErrorsKeepAllTokens(t *testing.T) {
+	input := "let = 5; x"
+	tree := Parse(input)
+	if len(tree.Errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if len(tree.Statements) != 1 || tree.Statements[0].Node != nil {
+		t.Fatalf("expected a single statement without a node. got = %+v", tree.Statements)
+	}
+	if len(tree.Statements[0].Tokens) != 5 {
+		t.Errorf("wrong number of tokens. got = %d", len(tree.Statements[0].Tokens))
+	}
+}
+
+func TestDeclaredOperatorTokens(t *testing.T) {
+	input := "let f = fn(a, b) { a<-b };\ninfix 4 left <- = f;\nx <- y"
+	tree := Parse(input)
+	if len(tree.Errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", tree.Errors)
+	}
+	if actual := Print(tree); actual != input {
+		t.Errorf("Print(Parse(%q)) wrong. got = %q", input, actual)
+	}
+
+	expected := [][]string{
+		{"let", "f", "=", "fn", "(", "a", ",", "b", ")", "{", "a", "<", "-", "b", "}", ";"},
+		{"infix", "4", "left", "<-", "=", "f", ";"},
+		{"x", "<-", "y"},
+	}
+	if len(tree.Statements) != len(expected) {
+		t.Fatalf("tree.Statements does not contain %d statements. got = %d", len(expected), len(tree.Statements))
+	}
+	for i, tokens := range expected {
+		var got []string
+		for _, tok := range tree.Statements[i].Tokens {
+			got = append(got, tok.Text)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tokens) {
+			t.Errorf("Statements[%d] tokens wrong. want = %q, got = %q", i, tokens, got)
+		}
+	}
+}
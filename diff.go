@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// edit is one line of a line diff: kept (' '), removed ('-') or added ('+')
+type edit struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the changes from a to b as a unified diff labelled with
+// filename, or "" when they are equal
+func unifiedDiff(filename, a, b string) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", filename, filename)
+	// line numbers before edits[i] in a and b
+	aLine, bLine := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if e.kind != '+' {
+			aLine[i+1]++
+		}
+		if e.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+		// a hunk runs until a stretch of unchanged lines too long to show as context
+		start := max(0, i-diffContext)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(len(edits), end+diffContext)
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.kind)
+			out.WriteString(e.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines from, which is the count of lines before the hunk, up to to
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines turns a into b with as few removed and added lines as possible. It uses
+// Myers' algorithm, which needs time proportional to the size of the inputs times
+// the number of changes, and space proportional to the size of the inputs only.
+// Within each run of changes the removed lines come before the added ones.
+func diffLines(a, b []string) []edit {
+	var edits []edit
+	diffRange(a, b, &edits)
+
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].kind != ' ' {
+			j++
+		}
+		run := edits[i:j]
+		sort.SliceStable(run, func(x, y int) bool { return run[x].kind == '-' && run[y].kind == '+' })
+		i = j
+	}
+	return edits
+}
+
+// diffRange appends the edits from a to b, splitting the problem at the middle of a
+// shortest edit script until what is left is trivial
+func diffRange(a, b []string, edits *[]edit) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for _, line := range a[:prefix] {
+		*edits = append(*edits, edit{' ', line})
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middleSnake(a, b); ok {
+		diffRange(a[:x], b[:y], edits)
+		diffRange(a[x:], b[y:], edits)
+	} else {
+		for _, line := range a {
+			*edits = append(*edits, edit{'-', line})
+		}
+		for _, line := range b {
+			*edits = append(*edits, edit{'+', line})
+		}
+	}
+	for _, line := range common {
+		*edits = append(*edits, edit{' ', line})
+	}
+}
+
+// middleSnake searches a shortest edit script from a to b from both ends at once and
+// returns a point on it where the two searches meet. It reports false when a and b
+// have no line in common, and when either is empty.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// forward[offset+k] and backward[offset+k] hold the furthest x reached on diagonal k,
+	// counted from the start of a for forward and from its end for backward, or -1
+	forward, backward := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+	// diagonals that ran off the edges of the grid are skipped from then on
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var x1 int
+			if k == -d || k != d && forward[i-1] < forward[i+1] {
+				x1 = forward[i+1]
+			} else {
+				x1 = forward[i-1] + 1
+			}
+			y1 := x1 - k
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			forward[i] = x1
+			switch {
+			case x1 > n:
+				fEnd += 2
+			case y1 > m:
+				fStart += 2
+			case odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && x1 >= n-backward[j] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var x2 int
+			if k == -d || k != d && backward[i-1] < backward[i+1] {
+				x2 = backward[i+1]
+			} else {
+				x2 = backward[i-1] + 1
+			}
+			y2 := x2 - k
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			backward[i] = x2
+			switch {
+			case x2 > n:
+				bEnd += 2
+			case y2 > m:
+				bStart += 2
+			case !odd:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					x1 := forward[j]
+					if x1 >= n-x2 {
+						return x1, offset + x1 - j, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n, with the lines in changed replaced by "x"
+func numbered(n int, changed ...int) string {
+	var out strings.Builder
+	for i := 1; i <= n; i++ {
+		line := strconv.Itoa(i)
+		for _, c := range changed {
+			if c == i {
+				line = "x"
+			}
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"equal", numbered(5), numbered(5), ""},
+		{
+			"one change",
+			numbered(10), numbered(10, 5),
+			"--- f\n+++ f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n",
+		},
+		{
+			"context cut at both ends",
+			numbered(3), numbered(3, 1, 3),
+			"--- f\n+++ f\n@@ -1,3 +1,3 @@\n-1\n+x\n 2\n-3\n+x\n",
+		},
+		{
+			"changes six lines apart share a hunk",
+			numbered(20), numbered(20, 5, 12),
+			"--- f\n+++ f\n@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+x\n 13\n 14\n 15\n",
+		},
+		{
+			"changes seven lines apart get their own hunks",
+			numbered(20), numbered(20, 5, 13),
+			"--- f\n+++ f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+x\n 6\n 7\n 8\n@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+x\n 14\n 15\n 16\n",
+		},
+		{
+			"removed and added lines",
+			"a\nb\nc\n", "a\nc\nd\n",
+			"--- f\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n c\n+d\n",
+		},
+		{"into empty file", "a\nb\n", "", "--- f\n+++ f\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"from empty file", "", "a\n", "--- f\n+++ f\n@@ -0,0 +1,1 @@\n+a\n"},
+	}
+
+	for _, tt := range tests {
+		if got := unifiedDiff("f", tt.a, tt.b); got != tt.expected {
+			t.Errorf("%s: wrong diff.\nwant = %q\ngot  = %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// TestDiffLines checks that the edits turn a into b and keep as many lines as possible
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		kept int
+	}{
+		{"a b c", "a b c", 3},
+		{"a b c a b b a", "c b a b a c", 4},
+		{"x y", "y z x", 1},
+		{"a a b", "b", 1},
+		{"a b", "c d", 0},
+		{"", "a", 0},
+	}
+
+	for _, tt := range tests {
+		var a, b []string
+		kept := 0
+		for _, e := range diffLines(strings.Fields(tt.a), strings.Fields(tt.b)) {
+			if e.kind != '+' {
+				a = append(a, e.line)
+			}
+			if e.kind != '-' {
+				b = append(b, e.line)
+			}
+			if e.kind == ' ' {
+				kept++
+			}
+		}
+		if strings.Join(a, " ") != tt.a || strings.Join(b, " ") != tt.b {
+			t.Errorf("diffLines(%q, %q) turns %q into %q", tt.a, tt.b, strings.Join(a, " "), strings.Join(b, " "))
+		}
+		if kept != tt.kept {
+			t.Errorf("diffLines(%q, %q) keeps %d lines. want = %d", tt.a, tt.b, kept, tt.kept)
+		}
+	}
+}
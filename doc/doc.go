@@ -0,0 +1,122 @@
+// Package doc extracts the documentation of the top-level bindings of a Monke
+// program, written as /// comments before a let statement, function declaration or
+// struct, and renders it as a Markdown or HTML reference page.
+package doc
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/BentleyOph/monke/ast"
+)
+
+// Binding is one top-level let statement, function declaration or struct of a program
+type Binding struct {
+	Name       string
+	Function   bool     // whether the name is bound to a function or macro literal, or is a struct constructor
+	Parameters []string // the parameters of the function as written, e.g. "b = 2" or "...rest"
+	Doc        string   // the text of the doc comments, "" when undocumented
+}
+
+// Bindings returns the top-level bindings of program in source order
+func Bindings(program *ast.Program) []Binding {
+	bindings := []Binding{}
+	for _, s := range program.Statements {
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			if s.Pattern != nil {
+				for _, name := range s.Names() {
+					bindings = append(bindings, Binding{Name: name.Value, Doc: s.Doc})
+				}
+				continue
+			}
+			b := Binding{Name: s.Name.Value, Doc: s.Doc}
+			switch lit := ast.Unparen(s.Value).(type) {
+			case *ast.FunctionLiteral:
+				b.Function = true
+				b.Parameters = parameters(lit)
+			case *ast.MacroLiteral:
+				b.Function = true
+				for _, param := range lit.Parameters {
+					b.Parameters = append(b.Parameters, param.Value)
+				}
+			}
+			bindings = append(bindings, b)
+		case *ast.FunctionStatement:
+			bindings = append(bindings, Binding{Name: s.Name.Value, Function: true, Parameters: parameters(s.Function), Doc: s.Doc})
+		case *ast.StructStatement:
+			// a struct binds its constructor, which takes the fields in order
+			b := Binding{Name: s.Name.Value, Function: true, Parameters: []string{}, Doc: s.Doc}
+			for _, f := range s.Fields {
+				b.Parameters = append(b.Parameters, f.Value)
+			}
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings
+}
+
+func parameters(fl *ast.FunctionLiteral) []string {
+	params := []string{}
+	for i, param := range fl.Parameters {
+		if value := fl.Default(i); value != nil {
+			params = append(params, param.Value+" = "+value.String())
+		} else {
+			params = append(params, param.Value)
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.Value)
+	}
+	return params
+}
+
+// Signature returns how the binding is used: "add(a, b)" for a function and its
+// name otherwise
+func (b Binding) Signature() string {
+	if !b.Function {
+		return b.Name
+	}
+	return b.Name + "(" + strings.Join(b.Parameters, ", ") + ")"
+}
+
+// paragraphs splits doc comment text at its empty lines
+func paragraphs(doc string) []string {
+	var paras []string
+	for _, para := range strings.Split(doc, "\n\n") {
+		if para = strings.Trim(para, "\n"); para != "" {
+			paras = append(paras, para)
+		}
+	}
+	return paras
+}
+
+// Markdown renders a reference page with a section for every binding
+func Markdown(title string, bindings []Binding) string {
+	var out bytes.Buffer
+	out.WriteString("# " + title + "\n")
+	for _, b := range bindings {
+		out.WriteString("\n## `" + b.Signature() + "`\n")
+		for _, para := range paragraphs(b.Doc) {
+			out.WriteString("\n" + para + "\n")
+		}
+	}
+	return out.String()
+}
+
+// HTML renders a standalone reference page with a section for every binding
+func HTML(title string, bindings []Binding) string {
+	var out bytes.Buffer
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString("<title>" + html.EscapeString(title) + "</title>\n</head>\n<body>\n")
+	out.WriteString("<h1>" + html.EscapeString(title) + "</h1>\n")
+	for _, b := range bindings {
+		out.WriteString("<h2 id=\"" + html.EscapeString(b.Name) + "\"><code>" + html.EscapeString(b.Signature()) + "</code></h2>\n")
+		for _, para := range paragraphs(b.Doc) {
+			out.WriteString("<p>" + html.EscapeString(para) + "</p>\n")
+		}
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
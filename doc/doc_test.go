@@ -0,0 +1,88 @@
+package doc
+
+import (
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+const source = `/// Adds two numbers.
+///
+/// The second one is optional.
+fn add(a, b = 1) { a + b }
+
+/// The ratio of a circle's circumference to its diameter, roughly.
+let pi = 3;
+
+let max = fn(first, ...rest) { first };
+add(pi, 2);
+/// Local bindings are not listed.
+let f = fn() { let g = 1; g };
+/// The parts of a point.
+let {x, y: [top, ...rest]} = point;
+/// A position on the screen.
+struct Point { x, y }
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestBindings(t *testing.T) {
+	bindings := Bindings(parse(t, source))
+	expected := []struct {
+		signature string
+		doc       string
+	}{
+		{"add(a, b = 1)", "Adds two numbers.\n\nThe second one is optional."},
+		{"pi", "The ratio of a circle's circumference to its diameter, roughly."},
+		{"max(first, ...rest)", ""},
+		{"f()", "Local bindings are not listed."},
+		{"x", "The parts of a point."},
+		{"top", "The parts of a point."},
+		{"rest", "The parts of a point."},
+		{"Point(x, y)", "A position on the screen."},
+	}
+
+	if len(bindings) != len(expected) {
+		t.Fatalf("wrong number of bindings. want = %d, got = %d", len(expected), len(bindings))
+	}
+	for i, want := range expected {
+		if bindings[i].Signature() != want.signature {
+			t.Errorf("bindings[%d] signature wrong. want = %q, got = %q", i, want.signature, bindings[i].Signature())
+		}
+		if bindings[i].Doc != want.doc {
+			t.Errorf("bindings[%d] doc wrong. want = %q, got = %q", i, want.doc, bindings[i].Doc)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	bindings := Bindings(parse(t, source))[:3]
+	expected := "# lib.mk\n" +
+		"\n## `add(a, b = 1)`\n\nAdds two numbers.\n\nThe second one is optional.\n" +
+		"\n## `pi`\n\nThe ratio of a circle's circumference to its diameter, roughly.\n" +
+		"\n## `max(first, ...rest)`\n"
+	if actual := Markdown("lib.mk", bindings); actual != expected {
+		t.Errorf("Markdown wrong.\nwant = %q\ngot  = %q", expected, actual)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	bindings := Bindings(parse(t, "/// Is a < b?\nlet less = fn(a, b) { a < b };"))
+	expected := "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>a&amp;b.mk</title>\n</head>\n<body>\n" +
+		"<h1>a&amp;b.mk</h1>\n" +
+		"<h2 id=\"less\"><code>less(a, b)</code></h2>\n<p>Is a &lt; b?</p>\n" +
+		"</body>\n</html>\n"
+	if actual := HTML("a&b.mk", bindings); actual != expected {
+		t.Errorf("HTML wrong.\nwant = %q\ngot  = %q", expected, actual)
+	}
+}
@@ -0,0 +1,492 @@
+// Package format prints Monke programs in one canonical layout: one statement per
+// line, blocks indented with tabs, single spaces around binary operators and after
+// commas, and at most one blank line between statements. Comments are kept, and call
+// arguments that do not fit in the configured width go on lines of their own.
+//
+// Comments stay where they were written. Call arguments and match arms with comments
+// between them go on lines of their own, and a statement with a comment anywhere else
+// inside it is printed as it was written.
+//
+// Formatting never changes what a program means: the parentheses of the source are
+// kept as written and no others are added, so the output parses into the same tree.
+package format
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/cst"
+	"github.com/BentleyOph/monke/token"
+)
+
+// DefaultWidth is the line width used when a Config does not set one
+const DefaultWidth = 80
+
+// tabWidth is the number of columns an indentation tab counts for when measuring lines
+const tabWidth = 4
+
+// Config controls the layout of formatted source
+type Config struct {
+	Width int // lines longer than this are broken where possible; 0 means DefaultWidth
+}
+
+// ErrorList holds the parser errors of source that cannot be formatted
+type ErrorList []string
+
+func (e ErrorList) Error() string {
+	return strings.Join(e, "\n")
+}
+
+// Source formats src with the default configuration
+func Source(src string) (string, error) {
+	return Config{}.Source(src)
+}
+
+// Source formats src, which has to parse without errors; otherwise the errors are
+// returned as an ErrorList. Formatted source is empty or ends with a newline.
+func (c Config) Source(src string) (string, error) {
+	tree := cst.Parse(src)
+	if len(tree.Errors) != 0 {
+		return "", ErrorList(tree.Errors)
+	}
+
+	p := &printer{src: src, width: c.Width}
+	if p.width <= 0 {
+		p.width = DefaultWidth
+	}
+	for _, tok := range tree.Tokens() {
+		for _, t := range tok.Leading {
+			if t.Kind == cst.Comment {
+				p.comments = append(p.comments, t)
+			}
+		}
+		for _, t := range tok.Trailing {
+			if t.Kind == cst.Comment {
+				p.comments = append(p.comments, t)
+			}
+		}
+	}
+	statements := make([]ast.Statement, len(tree.Statements))
+	for i, s := range tree.Statements {
+		statements[i] = s.Node
+	}
+
+	p.statements(statements, 0, len(src)+1)
+	if p.out.Len() == 0 {
+		return "", nil
+	}
+	return p.out.String() + "\n", nil
+}
+
+type printer struct {
+	src      string
+	width    int
+	flat     bool // never break lines; set while measuring how wide something is
+	indent   int
+	out      bytes.Buffer
+	comments []cst.Trivia // all comments of the source, in order
+	next     int          // index of the first comment not printed yet
+}
+
+func (p *printer) print(s string) {
+	p.out.WriteString(s)
+}
+
+// linebreak starts a new line at the current indentation, after an empty line if
+// blank is set. Nothing is written at the very start of the output.
+func (p *printer) linebreak(blank bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+	p.print("\n")
+	if blank {
+		p.print("\n")
+	}
+	p.print(strings.Repeat("\t", p.indent))
+}
+
+// comment prints the next comment
+func (p *printer) comment() cst.Trivia {
+	c := p.comments[p.next]
+	p.next++
+	p.print(strings.TrimRight(c.Text, " \t\r"))
+	return c
+}
+
+// hasComment reports whether the next comment starts before offset end
+func (p *printer) hasComment(end int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos < end
+}
+
+// blankLine reports whether the source between two items contains an empty line
+func blankLine(between string) bool {
+	return strings.Count(between, "\n") >= 2
+}
+
+// statements prints statements one per line together with the comments between
+// them, starting with the comments that are left before the first statement and
+// ending with those before offset end. Source offset start is where the list begins.
+func (p *printer) statements(statements []ast.Statement, start, end int) {
+	if p.hasComment(start) {
+		// a comment before the list, such as between the parameters of a function, has
+		// no place in this layout; the statement around the list is printed as written
+		return
+	}
+	prev := start
+	first := true
+	line := func(pos int) {
+		p.linebreak(!first && blankLine(p.src[prev:pos]))
+		first = false
+	}
+
+	for i, s := range statements {
+		for p.hasComment(s.Pos()) {
+			line(p.comments[p.next].Pos)
+			c := p.comment()
+			prev = c.Pos + len(c.Text)
+		}
+
+		line(s.Pos())
+		mark, printed := p.out.Len(), p.next
+		p.statement(s)
+		if p.hasComment(s.End()) {
+			// a comment inside s that the layout has no place for
+			p.out.Truncate(mark)
+			p.next = printed
+			p.verbatim(s)
+		}
+		if _, ok := s.(*ast.ExpressionStatement); ok && i < len(statements)-1 && continues(statements[i+1]) {
+			p.print(";")
+		}
+		prev = s.End()
+
+		// the comment following the statement on its last line
+		next := end
+		if i < len(statements)-1 {
+			next = statements[i+1].Pos()
+		}
+		if p.hasComment(next) && !strings.Contains(p.src[prev:p.comments[p.next].Pos], "\n") {
+			p.print(" ")
+			c := p.comment()
+			prev = c.Pos + len(c.Text)
+		}
+	}
+
+	for p.hasComment(end) {
+		line(p.comments[p.next].Pos)
+		c := p.comment()
+		prev = c.Pos + len(c.Text)
+	}
+}
+
+// verbatim prints s as it is written in the source, including its comments
+func (p *printer) verbatim(s ast.Statement) {
+	p.print(p.src[s.Pos():s.End()])
+	for p.hasComment(s.End()) {
+		p.next++
+	}
+	switch s.(type) {
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.InfixStatement:
+		p.print(";")
+	}
+}
+
+// interleave prints the comments before offset end that follow an item ending at
+// offset prev, keeping a comment on the line of that item when it was there in the source
+func (p *printer) interleave(prev, end int) {
+	for p.hasComment(end) && p.comments[p.next].Pos >= prev {
+		c := p.comments[p.next]
+		if strings.Contains(p.src[prev:c.Pos], "\n") {
+			p.linebreak(false)
+		} else {
+			p.print(" ")
+		}
+		p.comment()
+		prev = c.Pos + len(c.Text)
+	}
+}
+
+// continues reports whether s could be read as the continuation of an expression
+// statement before it, as in "a" followed by "(b)" or "-b", so that the two have to
+// be separated by a semicolon
+func continues(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch es.Token.Type {
+	case token.IDENT, token.INT, token.STRING, token.TRUE, token.FALSE, token.NULL, token.BANG, token.IF, token.MATCH, token.FUNCTION, token.MACRO:
+		return false
+	}
+	return true
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.let(s)
+		p.print(";")
+	case *ast.ReturnStatement:
+		p.print("return ")
+		p.expression(s.ReturnValue)
+		p.print(";")
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression)
+	case *ast.FunctionStatement:
+		p.print("fn " + s.Name.Value)
+		p.function(s.Function)
+	case *ast.InfixStatement:
+		p.print("infix " + strconv.Itoa(s.Precedence) + " " + s.Associativity + " " + s.Operator + " = ")
+		p.expression(s.Function)
+		p.print(";")
+	case *ast.StructStatement:
+		p.print("struct " + s.Name.Value + " {")
+		for i, f := range s.Fields {
+			if i > 0 {
+				p.print(",")
+			}
+			p.print(" " + f.Value)
+		}
+		if len(s.Fields) > 0 {
+			p.print(" ")
+		}
+		p.print("}")
+	case *ast.WhileStatement:
+		p.print("while (")
+		p.expression(s.Condition)
+		p.print(") ")
+		p.block(s.Body)
+	case *ast.ForStatement:
+		p.print("for (")
+		switch init := s.Init.(type) {
+		case *ast.LetStatement:
+			p.let(init)
+		case *ast.ExpressionStatement:
+			p.expression(init.Expression)
+		}
+		p.print(";")
+		if s.Condition != nil {
+			p.print(" ")
+			p.expression(s.Condition)
+		}
+		p.print(";")
+		if s.Post != nil {
+			p.print(" ")
+			p.expression(s.Post)
+		}
+		p.print(") ")
+		p.block(s.Body)
+	default:
+		p.print(s.String())
+	}
+}
+
+// let prints a let statement without its semicolon
+func (p *printer) let(ls *ast.LetStatement) {
+	if ls.Pattern != nil {
+		p.print("let " + ls.Pattern.String() + " = ")
+	} else {
+		p.print("let " + ls.Name.Value + " = ")
+	}
+	p.expression(ls.Value)
+}
+
+func (p *printer) block(bs *ast.BlockStatement) {
+	if len(bs.Statements) == 0 && !p.hasComment(bs.Rbrace.Pos) {
+		p.print("{}")
+		return
+	}
+	p.print("{")
+	p.indent++
+	p.statements(bs.Statements, bs.Token.End, bs.Rbrace.Pos)
+	p.indent--
+	p.linebreak(false)
+	p.print("}")
+}
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.print(e.Value)
+	case *ast.IntegerLiteral:
+		p.print(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.print(`"` + e.Value + `"`)
+	case *ast.Boolean:
+		p.print(e.Token.Literal)
+	case *ast.NullLiteral:
+		p.print("null")
+	case *ast.PrefixExpression:
+		p.print(e.Operator)
+		if right, ok := e.Right.(*ast.PrefixExpression); ok && e.Operator == "-" && right.Operator == "-" {
+			p.print(" ") // "- -x" rather than "--x"
+		}
+		p.expression(e.Right)
+	case *ast.InfixExpression:
+		p.expression(e.Left)
+		p.print(" " + e.Operator + " ")
+		p.expression(e.Right)
+	case *ast.AssignExpression:
+		p.expression(e.Target)
+		p.print(" " + e.Operator + " ")
+		p.expression(e.Value)
+	case *ast.ParenExpression:
+		p.print("(")
+		p.expression(e.Expression)
+		p.print(")")
+	case *ast.IfExpression:
+		p.print("if (")
+		p.expression(e.Condition)
+		p.print(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.print(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.print("fn")
+		p.function(e)
+	case *ast.MacroLiteral:
+		p.print("macro(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.print(param.Value)
+		}
+		p.print(") ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expression(e.Function)
+		p.arguments(e)
+	case *ast.IndexExpression:
+		p.expression(e.Left)
+		if e.Optional {
+			p.print("?")
+		}
+		p.print("[")
+		p.expression(e.Index)
+		p.print("]")
+	case *ast.MemberExpression:
+		p.expression(e.Object)
+		if e.Optional {
+			p.print("?")
+		}
+		p.print("." + e.Property.Value)
+	case *ast.MatchExpression:
+		p.match(e)
+	default:
+		p.print(e.String())
+	}
+}
+
+// match prints a match expression with one arm per line, each followed by a comma
+func (p *printer) match(me *ast.MatchExpression) {
+	p.print("match (")
+	p.expression(me.Subject)
+	p.print(") {")
+	if len(me.Arms) == 0 {
+		p.print("}")
+		return
+	}
+	p.indent++
+	prev := me.Subject.End()
+	for _, arm := range me.Arms {
+		p.interleave(prev, arm.Pos())
+		p.linebreak(false)
+		p.print(arm.Pattern.String())
+		if arm.Guard != nil {
+			p.print(" if ")
+			p.expression(arm.Guard)
+		}
+		p.print(" => ")
+		p.expression(arm.Body)
+		p.print(",")
+		prev = arm.End()
+	}
+	p.interleave(prev, me.Rbrace.Pos)
+	p.indent--
+	p.linebreak(false)
+	p.print("}")
+}
+
+// function prints the parameters and body of a function
+func (p *printer) function(fl *ast.FunctionLiteral) {
+	p.print("(")
+	for i, param := range fl.Parameters {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(param.Value)
+		if value := fl.Default(i); value != nil {
+			p.print(" = ")
+			p.expression(value)
+		}
+	}
+	if fl.Rest != nil {
+		if len(fl.Parameters) > 0 {
+			p.print(", ")
+		}
+		p.print("..." + fl.Rest.Value)
+	}
+	p.print(") ")
+	p.block(fl.Body)
+}
+
+// arguments prints the arguments of a call, one per line when they do not fit on the
+// current line or have comments between them
+func (p *printer) arguments(ce *ast.CallExpression) {
+	args := ce.Arguments
+	if p.flat || len(args) == 0 || !p.hasComment(ce.Rparen.Pos) && p.fits(func(q *printer) { q.arguments(ce) }) {
+		p.print("(")
+		for i, arg := range args {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expression(arg)
+		}
+		p.print(")")
+		return
+	}
+
+	p.print("(")
+	p.indent++
+	prev := ce.Token.End
+	for i, arg := range args {
+		p.interleave(prev, arg.Pos())
+		p.linebreak(false)
+		p.expression(arg)
+		if i < len(args)-1 {
+			p.print(",")
+		}
+		prev = arg.End()
+	}
+	p.interleave(prev, ce.Rparen.Pos)
+	p.indent--
+	p.linebreak(false)
+	p.print(")")
+}
+
+// fits reports whether the first line of what print writes fits on the current line
+func (p *printer) fits(print func(q *printer)) bool {
+	q := &printer{src: p.src, width: p.width, flat: true, indent: p.indent, comments: p.comments, next: p.next}
+	print(q)
+	written := q.out.String()
+	if n := strings.IndexByte(written, '\n'); n >= 0 {
+		written = written[:n]
+	}
+	return p.column()+utf8.RuneCountInString(written) <= p.width
+}
+
+// column returns the width of the current line so far
+func (p *printer) column() int {
+	line := p.out.Bytes()
+	if n := bytes.LastIndexByte(line, '\n'); n >= 0 {
+		line = line[n+1:]
+	}
+	tabs := bytes.Count(line, []byte("\t"))
+	return utf8.RuneCount(line) - tabs + tabs*tabWidth
+}
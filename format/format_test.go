@@ -0,0 +1,198 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"  \n\n", ""},
+		{"let   x=5 ;", "let x = 5;\n"},
+		{"return -a*(b+c)", "return -a * (b + c);\n"},
+		{"a; b;c", "a\nb\nc\n"},
+		{"a; (b); -c", "a;\n(b);\n-c\n"},
+		{"!-x; - -x", "!-x;\n- -x\n"},
+		{`f( 1,"s" ,true)[0]`, "f(1, \"s\", true)[0]\n"},
+		{"x+=1;a[i]=2", "x += 1\na[i] = 2\n"},
+		{"cfg?.db?.host??\"localhost\"; null; a?[0]", "cfg?.db?.host ?? \"localhost\"\nnull\na?[0]\n"},
+		{"if(x<y){x}else{y}", "if (x < y) {\n\tx\n} else {\n\ty\n}\n"},
+		{"if (x) {}", "if (x) {}\n"},
+		{"let f = fn(a,b=2,...c){return a;};", "let f = fn(a, b = 2, ...c) {\n\treturn a;\n};\n"},
+		{"let m = macro(a,b){quote(unquote(a)+b)};", "let m = macro(a, b) {\n\tquote(unquote(a) + b)\n};\n"},
+		{"let r = match(x){1=>\"one\",[a,...b] if a>0=>a,{\"k\":v}=>v,_=>null};", "let r = match (x) {\n\t1 => \"one\",\n\t[a, ...b] if a > 0 => a,\n\t{\"k\": v} => v,\n\t_ => null,\n};\n"},
+		{"match (x) {}", "match (x) {}\n"},
+		{"struct Point{x,y};struct Unit { }\np . x=p.y", "struct Point { x, y }\nstruct Unit {}\np.x = p.y\n"},
+		{"let [a,b,...r]=xs;let {name,age:years}=p;", "let [a, b, ...r] = xs;\nlet {name, age: years} = p;\n"},
+		{"for(let [i,n]=pair;i<n;i+=1){}", "for (let [i, n] = pair; i < n; i += 1) {}\n"},
+		{"fn f(){ if (a) { b } }", "fn f() {\n\tif (a) {\n\t\tb\n\t}\n}\n"},
+		{"while(true){break;}", "while (true) {\n\tbreak;\n}\n"},
+		{"for(;;){continue;}", "for (;;) {\n\tcontinue;\n}\n"},
+		{"for(let i=0;i<n;i+=1){}", "for (let i = 0; i < n; i += 1) {}\n"},
+		{"infix 5 right <+> = add; 1<+>2", "infix 5 right <+> = add;\n1 <+> 2\n"},
+		{"let x = 1;\n\n\n\nlet y = 2;\nlet z = 3;", "let x = 1;\n\nlet y = 2;\nlet z = 3;\n"},
+		{"fn f() {\n\n  a\n\n  b\n\n}", "fn f() {\n\ta\n\n\tb\n}\n"},
+	}
+
+	for _, tt := range tests {
+		actual, err := Source(tt.input)
+		if err != nil {
+			t.Fatalf("Source(%q) returned an error: %v", tt.input, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant = %q\ngot  = %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestSourceKeepsComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// only a comment  ", "// only a comment\n"},
+		{"// header\n\n\nlet x = 5;   // five\n// footer", "// header\n\nlet x = 5; // five\n// footer\n"},
+		{"let f = fn() { // start\n  a // value\n  // end\n};", "let f = fn() {\n\t// start\n\ta // value\n\t// end\n};\n"},
+		{"fn f() {\n// nothing\n}", "fn f() {\n\t// nothing\n}\n"},
+		{"f(1, // one\n  2);\ng()", "f(\n\t1, // one\n\t2\n)\ng()\n"},
+		{"let x = f(\n 1, // one\n 2\n);", "let x = f(\n\t1, // one\n\t2\n);\n"},
+		{"f( // args\n 1,\n // before two\n 2)", "f( // args\n\t1,\n\t// before two\n\t2\n)\n"},
+		{"match (x) {\n // first\n 1 => a, // one\n 2 => b // two\n}", "match (x) {\n\t// first\n\t1 => a, // one\n\t2 => b, // two\n}\n"},
+		{"fn g() {\n  let y = a + // c\n    b\n  h(1)\n}", "fn g() {\n\tlet y = a + // c\n    b;\n\th(1)\n}\n"},
+		{"if (x) { a } // trailing\nelse { b }", "if (x) { a } // trailing\nelse { b }\n"},
+		{"let f = fn(a, // c\n b) { a + b };\nf(1)", "let f = fn(a, // c\n b) { a + b };\nf(1)\n"},
+		{"fn g(a, // c\n b) {}", "fn g(a, // c\n b) {}\n"},
+		{"for (let i = 0; // init\n i < 3; i += 1) { i }", "for (let i = 0; // init\n i < 3; i += 1) { i }\n"},
+		{"while (x) { while (y // c\n) { z } }", "while (x) {\n\twhile (y // c\n) { z }\n}\n"},
+		{"a // x\r\nb\r\n", "a // x\nb\n"},
+		{"/// Adds.\n///\n/// Really.  \nfn add(a,b){a+b}", "/// Adds.\n///\n/// Really.\nfn add(a, b) {\n\ta + b\n}\n"},
+	}
+
+	for _, tt := range tests {
+		actual, err := Source(tt.input)
+		if err != nil {
+			t.Fatalf("Source(%q) returned an error: %v", tt.input, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Source(%q) wrong.\nwant = %q\ngot  = %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	input := "let result = combine(first, second, transform(third, fourth));"
+	tests := []struct {
+		width    int
+		expected string
+	}{
+		{0, input + "\n"},
+		{80, input + "\n"},
+		{40, "let result = combine(\n\tfirst,\n\tsecond,\n\ttransform(third, fourth)\n);\n"},
+		{20, "let result = combine(\n\tfirst,\n\tsecond,\n\ttransform(\n\t\tthird,\n\t\tfourth\n\t)\n);\n"},
+	}
+
+	for _, tt := range tests {
+		actual, err := Config{Width: tt.width}.Source(input)
+		if err != nil {
+			t.Fatalf("Source with width %d returned an error: %v", tt.width, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Source with width %d wrong.\nwant = %q\ngot  = %q", tt.width, tt.expected, actual)
+		}
+	}
+}
+
+// TestSourceIsStable checks that formatting keeps the tree and every comment, and
+// that formatted source is left as it is
+func TestSourceIsStable(t *testing.T) {
+	inputs := []string{
+		"// header\nlet add = fn(a, b) { a + b; }; add(1, 2 * 3)[0];",
+		"if (x < y) { x } else { y }\n(1 + 2) * 3",
+		"for (let i = 0; i < 10; i += 1) { if (i == 5) { break; } // stop\n }",
+		"infix 6 right <+> = fn(a, b) { a }; 1 <+> 2 <+> 3 // right",
+		"let x = f(\n 1, // one\n 2\n); let y = a + // c\n b;",
+		"fn even(n) { if (n == 0) { true } else { odd(n - 1) } }\n\n\nfn odd(n) { !even(n) }",
+		"someFunctionWithALongName(anArgumentWithALongName, fn(x) { x * x }, \"a string that is long\")",
+		"let x = -(-1); a[b[c]] = f(g)(h);",
+	}
+
+	for _, input := range inputs {
+		for _, width := range []int{10, 40, 80} {
+			config := Config{Width: width}
+			formatted, err := config.Source(input)
+			if err != nil {
+				t.Fatalf("Source(%q) returned an error: %v", input, err)
+			}
+			if !ast.Equal(parse(t, input), parse(t, formatted)) {
+				t.Errorf("formatting %q changed the tree. got = %q", input, formatted)
+			}
+			if want, got := strings.Count(input, "//"), strings.Count(formatted, "//"); want != got {
+				t.Errorf("formatting %q lost comments. got = %q", input, formatted)
+			}
+			if again, _ := config.Source(formatted); again != formatted {
+				t.Errorf("formatting %q again changed it.\nfirst  = %q\nsecond = %q", input, formatted, again)
+			}
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source("let = 5;")
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("err is not an ErrorList. got = %T (%v)", err, err)
+	}
+	if len(errs) == 0 || errs[0] != "expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong errors. got = %q", errs)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func FuzzSource(f *testing.F) {
+	f.Add("// header\nlet add = fn(a, b) { a + b; }; add(1, 2 * 3)[0];")
+	f.Add("if (x) { a // x\n } else { b }\n\n\n(1)")
+	f.Add("for (;;) { f(1, // one\n 2) }")
+	f.Add("match (x) { 1 => a, // one\n _ => b + // two\n c }")
+	f.Add("if (x) { a } // trailing\nelse { b }; fn(a, // c\n b) { a }")
+	f.Add("infix 6 right <+> = fn(a, b) { a }; 1 <+> 2 <+> 3")
+	f.Add("cfg?.db?.host ?? null; a?[0]")
+	f.Add(`match (x) { [a, ...r] if a > 0 => r, {"k": -1} => 1, _ => 2 }`)
+	f.Add("let [a, ...r] = xs; let {name, age: years} = person;")
+	f.Add("struct Point { x, y } Point(1, 2).x; p.x = 3")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+		config := Config{Width: 20}
+		formatted, err := config.Source(input)
+		if err != nil {
+			t.Fatalf("Source(%q) returned an error: %v", input, err)
+		}
+		if !ast.Equal(program, parse(t, formatted)) {
+			t.Fatalf("formatting %q changed the tree. got = %q", input, formatted)
+		}
+		if again, _ := config.Source(formatted); again != formatted {
+			t.Fatalf("formatting %q again changed it.\nfirst  = %q\nsecond = %q", input, formatted, again)
+		}
+	})
+}
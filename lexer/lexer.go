@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"github.com/BentleyOph/monke/token"
+	"sort"
+	"strings"
 	"unicode"
 )
 
@@ -10,6 +12,14 @@ type Lexer struct {
 	position     int  // current position in input (points to current char which is ch)
 	readPosition int  // current reading position in input (after current char)/peeking
 	ch           byte // current char under examination
+
+	keywords  map[string]token.TokenType // keywords registered on top of token.LookupIdent
+	operators []operator                 // registered operators, longest literal first
+}
+
+type operator struct {
+	literal   string
+	tokenType token.TokenType
 }
 
 //position and readPosition are used to access characters in the input string
@@ -31,10 +41,78 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+// RegisterKeyword makes the identifier word lex as tokenType for this lexer only
+func (l *Lexer) RegisterKeyword(word string, tokenType token.TokenType) {
+	if l.keywords == nil {
+		l.keywords = make(map[string]token.TokenType)
+	}
+	l.keywords[word] = tokenType
+}
+
+// RegisterOperator makes literal lex as a single token of tokenType.
+// Registered operators take priority over the built-in ones and are matched longest first,
+// so registering "==>" still leaves "=" and "==" intact.
+func (l *Lexer) RegisterOperator(literal string, tokenType token.TokenType) {
+	for i, op := range l.operators {
+		if op.literal == literal {
+			l.operators[i].tokenType = tokenType
+			return
+		}
+	}
+	l.operators = append(l.operators, operator{literal: literal, tokenType: tokenType})
+	sort.SliceStable(l.operators, func(i, j int) bool {
+		return len(l.operators[i].literal) > len(l.operators[j].literal)
+	})
+}
+
+// readOperator consumes a registered operator starting at the current character, if there is one
+func (l *Lexer) readOperator() (token.Token, bool) {
+	if l.position >= len(l.input) {
+		return token.Token{}, false
+	}
+	for _, op := range l.operators {
+		if strings.HasPrefix(l.input[l.position:], op.literal) {
+			for i := 0; i < len(op.literal); i++ {
+				l.readChar()
+			}
+			return token.Token{Type: op.tokenType, Literal: op.literal}, true
+		}
+	}
+	return token.Token{}, false
+}
+
+func (l *Lexer) lookupIdent(ident string) token.TokenType {
+	if tok, ok := l.keywords[ident]; ok {
+		return tok
+	}
+	return token.LookupIdent(ident)
+}
+
 // NextToken returns the next token in the input string
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
 	l.skipWhiteSpace()
+	start := l.offset()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.offset()
+	return tok
+}
+
+// offset returns the byte offset of the current character, which is len(input) once the input is exhausted
+func (l *Lexer) offset() int {
+	if l.position > len(l.input) {
+		return len(l.input)
+	}
+	return l.position
+}
+
+// readToken reads the token starting at the current character
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
+	if tok, ok := l.readOperator(); ok {
+		return tok
+	}
 
 	switch l.ch {
 	case '=':
@@ -42,11 +120,16 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -54,9 +137,9 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newCompoundToken(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newCompoundToken(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -66,9 +149,20 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = l.newCompoundToken(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.atDocComment() {
+			start := l.position + len("///")
+			l.skipComment()
+			return token.Token{Type: token.DOC, Literal: l.input[start:l.position]}
+		}
+		tok = l.newCompoundToken(token.SLASH, token.SLASH_ASSIGN)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -77,16 +171,46 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		case '.':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_DOT, Literal: "?."}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		default:
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
 	case 0:
+		if l.position < len(l.input) { // a NUL byte in the middle of the input
+			tok = newToken(token.ILLEGAL, l.ch)
+			break
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default: // if the character is not a special character, then it is an identifier
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Type = l.lookupIdent(tok.Literal)
 			return tok
 		} else if unicode.IsDigit(rune(l.ch)) {
 			tok.Type = token.INT
@@ -104,6 +228,17 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// newCompoundToken returns an assignOp token such as += when the current character is followed by '=',
+// and a single character token of tokenType otherwise
+func (l *Lexer) newCompoundToken(tokenType, assignOp token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return token.Token{Type: assignOp, Literal: string(ch) + string(l.ch)}
+	}
+	return newToken(tokenType, l.ch)
+}
+
 func (l *Lexer) readIdentifier() string { //readIdentifier reads an identifier and advances the lexer's position
 	position := l.position
 	for isLetter(l.ch) {
@@ -120,12 +255,68 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position] // for example, if the input is "1234;", the function will return "1234" position is 0 and l.position is 4
 }
 
+// ReadSymbol consumes the run of operator characters that directly follows the last token
+// returned by NextToken. The parser uses it to lex a newly declared operator such as <+>,
+// which would otherwise be split into the built-in tokens it is made of.
+func (l *Lexer) ReadSymbol() string {
+	position := l.position
+	for IsOperatorChar(l.ch) {
+		l.readChar()
+	}
+	if position >= len(l.input) {
+		return ""
+	}
+	return l.input[position:l.position]
+}
+
+// builtinOperators are the operators of more than one character that readToken reads
+var builtinOperators = []string{"==", "=>", "!=", "**", "+=", "-=", "*=", "/=", "...", "??", "?.", "?["}
+
+// Hides returns a built-in operator that literal is a proper prefix of. Registered
+// operators are matched before the built-in ones, so registering literal would make
+// that operator impossible to write.
+func Hides(literal string) (string, bool) {
+	for _, op := range builtinOperators {
+		if len(literal) < len(op) && strings.HasPrefix(op, literal) {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// IsOperatorChar reports whether ch may appear in a user-declared operator
+func IsOperatorChar(ch byte) bool {
+	return ch != 0 && strings.IndexByte("!$%&*+-./:<=>?@^|~", ch) >= 0
+}
+
 func isLetter(ch byte) bool {
 	return unicode.IsLetter(rune(ch)) || ch == '_'
 }
 
+// skipWhiteSpace skips whitespace and // comments, but not /// doc comments
 func (l *Lexer) skipWhiteSpace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/' && !l.atDocComment():
+			l.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+// atDocComment reports whether a /// doc comment starts at the current character.
+// Four or more slashes make an ordinary comment, such as a line of slashes used as a separator.
+func (l *Lexer) atDocComment() bool {
+	rest := l.input[min(l.position, len(l.input)):]
+	return strings.HasPrefix(rest, "///") && !strings.HasPrefix(rest, "////")
+}
+
+// skipComment skips a comment up to, but not including, the end of the line
+func (l *Lexer) skipComment() {
+	for l.position < len(l.input) && l.ch != '\n' && l.ch != '\r' {
 		l.readChar()
 	}
 }
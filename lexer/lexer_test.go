@@ -25,6 +25,13 @@ return false;
 "foobar"
 "foo bar"
 }
+2 ** 3;
+while for break continue
+x += 1; x -= 1; x *= 1; x /= 1; a[0]
+...rest ..
+null a ?? b?.c?[0] ?
+match (x) { {"a": 1} => y }
+struct Point { x } p.x
 
 `
 
@@ -107,6 +114,69 @@ return false;
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
 		{token.RBRACE, "}"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.NULL, "null"},
+		{token.IDENT, "a"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "b"},
+		{token.OPTIONAL_DOT, "?."},
+		{token.IDENT, "c"},
+		{token.OPTIONAL_LBRACKET, "?["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.ILLEGAL, "?"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.STRUCT, "struct"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
 	
 		{token.EOF, ""},
 	}
@@ -124,3 +194,135 @@ return false;
 		}
 	}
 }
+
+func TestRegisteredTokens(t *testing.T) {
+	input := `x in xs => y == z = w`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"},
+		{"IN", "in"},
+		{token.IDENT, "xs"},
+		{"ARROW", "=>"},
+		{token.IDENT, "y"},
+		{token.EQ, "=="},
+		{token.IDENT, "z"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "w"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	l.RegisterKeyword("in", "IN")
+	l.RegisterOperator("=>", "ARROW")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := "/// Adds.\r\n//// not a doc\nlet x = 1 /// after\n// plain\n///"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     int
+		expectedEnd     int
+	}{
+		{token.DOC, " Adds.", 0, 9},
+		{token.LET, "let", 26, 29},
+		{token.IDENT, "x", 30, 31},
+		{token.ASSIGN, "=", 32, 33},
+		{token.INT, "1", 34, 35},
+		{token.DOC, " after", 36, 45},
+		{token.DOC, "", 55, 58},
+		{token.EOF, "", 58, 58},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%q %q, got=%q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos || tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - position wrong. expected=%d-%d, got=%d-%d", i, tt.expectedPos, tt.expectedEnd, tok.Pos, tok.End)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = \"hi\"; // note\n  x ** 2 // end"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     int
+		expectedEnd     int
+	}{
+		{token.LET, "let", 0, 3},
+		{token.IDENT, "x", 4, 5},
+		{token.ASSIGN, "=", 6, 7},
+		{token.STRING, "hi", 8, 12},
+		{token.SEMICOLON, ";", 12, 13},
+		{token.IDENT, "x", 24, 25},
+		{token.POWER, "**", 26, 28},
+		{token.INT, "2", 29, 30},
+		{token.EOF, "", 37, 37},
+		{token.EOF, "", 37, 37},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos || tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - position wrong. expected=%d-%d, got=%d-%d", i, tt.expectedPos, tt.expectedEnd, tok.Pos, tok.End)
+		}
+	}
+}
+
+func FuzzNextToken(f *testing.F) {
+	f.Add("let add = fn(x, y) { x + y; }; // sum")
+	f.Add(`"unterminated`)
+	f.Add("!-/*5 ** 2 += [1] \x00 @")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+		end := 0
+		for i := 0; i <= len(input)+1; i++ {
+			tok := l.NextToken()
+			if tok.Pos < end || tok.End < tok.Pos || tok.End > len(input) {
+				t.Fatalf("token %d (%q) has bad position %d-%d after offset %d", i, tok.Literal, tok.Pos, tok.End, end)
+			}
+			if tok.Type == token.EOF {
+				if tok.Pos != len(input) {
+					t.Fatalf("EOF at %d before the end of the input", tok.Pos)
+				}
+				return
+			}
+			if tok.End == tok.Pos {
+				t.Fatalf("token %d (%q) is empty", i, tok.Literal)
+			}
+			end = tok.End
+		}
+		t.Fatalf("lexer did not reach EOF")
+	})
+}
@@ -0,0 +1,145 @@
+// Package macro expands the macros of a Monke program before it runs. A macro is
+// bound with a let statement at the top level of the program,
+//
+//	let unless = macro(condition, consequence, alternative) {
+//		quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) })
+//	};
+//
+// and every call unless(a, b, c) is replaced by the expression the body quotes, with
+// each unquote(e) in it replaced by e, in which the parameters of the macro stand
+// for the expressions the call passed as arguments.
+//
+// Expansion is purely syntactic. There is no evaluator to run a macro body, so the
+// body has to be a single quote(...) expression, and the expression inside unquote
+// is spliced in as it is instead of being evaluated first.
+package macro
+
+import (
+	"fmt"
+
+	"github.com/BentleyOph/monke/ast"
+)
+
+// Macros maps the names of macros to their definitions
+type Macros map[string]*ast.MacroLiteral
+
+// maxRounds limits how often expansion is repeated for the macro calls that expansions
+// contain, of which a recursive macro produces an endless supply
+const maxRounds = 100
+
+// DefineMacros removes the macro definitions from the top level of program and
+// returns them
+func DefineMacros(program *ast.Program) Macros {
+	macros := Macros{}
+	statements := []ast.Statement{}
+	for _, s := range program.Statements {
+		if let, ok := s.(*ast.LetStatement); ok && let.Name != nil {
+			if m, ok := ast.Unparen(let.Value).(*ast.MacroLiteral); ok {
+				macros[let.Name.Value] = m
+				continue
+			}
+		}
+		statements = append(statements, s)
+	}
+	program.Statements = statements
+	return macros
+}
+
+// ExpandMacros replaces every call of one of macros in the tree rooted at node by
+// its expansion, repeating this for the macro calls the expansions contain. Calls
+// that cannot be expanded are left in place and reported as errors.
+func ExpandMacros(node ast.Node, macros Macros) (ast.Node, []string) {
+	errors := []string{}
+	for round := 0; ; round++ {
+		if round == maxRounds {
+			errors = append(errors, fmt.Sprintf("macro expansion did not finish after %d rounds", maxRounds))
+			return node, errors
+		}
+		expanded := false
+		node = ast.Modify(node, func(node ast.Node) ast.Node {
+			call, ok := node.(*ast.CallExpression)
+			if !ok {
+				return node
+			}
+			name, ok := ast.Unparen(call.Function).(*ast.Identifier)
+			if !ok || macros[name.Value] == nil {
+				return node
+			}
+			expansion, err := expand(name.Value, macros[name.Value], call.Arguments)
+			if err != "" {
+				errors = append(errors, err)
+				return node
+			}
+			expanded = true
+			return expansion
+		})
+		if !expanded || len(errors) > 0 {
+			return node, errors
+		}
+	}
+}
+
+// expand returns the expansion of a call of macro m, or an error message
+func expand(name string, m *ast.MacroLiteral, arguments []ast.Expression) (ast.Expression, string) {
+	if len(arguments) != len(m.Parameters) {
+		return nil, fmt.Sprintf("wrong number of arguments to macro %s: want %d, got %d", name, len(m.Parameters), len(arguments))
+	}
+	quoted := quotedExpression(m)
+	if quoted == nil {
+		return nil, fmt.Sprintf("macro %s must consist of a single quote(...) expression", name)
+	}
+	args := map[string]ast.Expression{}
+	for i, param := range m.Parameters {
+		args[param.Value] = arguments[i]
+	}
+
+	err := ""
+	expansion := ast.Modify(ast.Clone(quoted), func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !isCallOf(call, "unquote") {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = fmt.Sprintf("unquote in macro %s takes exactly one argument, got %d", name, len(call.Arguments))
+			return node
+		}
+		return substitute(call.Arguments[0], args)
+	})
+	if err != "" {
+		return nil, err
+	}
+	return expansion.(ast.Expression), ""
+}
+
+// quotedExpression returns e of a macro body that is just quote(e), or nil
+func quotedExpression(m *ast.MacroLiteral) ast.Expression {
+	if len(m.Body.Statements) != 1 {
+		return nil
+	}
+	stmt, ok := m.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil
+	}
+	call, ok := ast.Unparen(stmt.Expression).(*ast.CallExpression)
+	if !ok || !isCallOf(call, "quote") || len(call.Arguments) != 1 {
+		return nil
+	}
+	return call.Arguments[0]
+}
+
+func isCallOf(call *ast.CallExpression, name string) bool {
+	ident, ok := ast.Unparen(call.Function).(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// substitute returns a copy of e in which the parameters are replaced by copies of their arguments
+func substitute(e ast.Expression, args map[string]ast.Expression) ast.Expression {
+	return ast.Modify(ast.Clone(e), func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if arg, ok := args[ident.Value]; ok {
+				return ast.Clone(arg)
+			}
+		}
+		return node
+	}).(ast.Expression)
+}
@@ -0,0 +1,132 @@
+package macro
+
+import (
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestDefineMacros(t *testing.T) {
+	program := parse(t, `let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { quote(x + y) };
+	mymacro(1, 2);`)
+
+	macros := DefineMacros(program)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("wrong number of statements. want 3, got = %d: %s", len(program.Statements), program.String())
+	}
+	for _, name := range []string{"number", "function"} {
+		if _, ok := macros[name]; ok {
+			t.Errorf("%s should not be defined as a macro", name)
+		}
+	}
+	m, ok := macros["mymacro"]
+	if !ok {
+		t.Fatalf("macro mymacro not defined")
+	}
+	if len(m.Parameters) != 2 || m.Parameters[0].Value != "x" || m.Parameters[1].Value != "y" {
+		t.Errorf("wrong macro parameters: %v", m.Parameters)
+	}
+	if m.Body.String() != "{ quote((x + y)) }" {
+		t.Errorf("wrong macro body. got = %q", m.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let infixExpression = macro() { quote(1 + 2) }; infixExpression();",
+			"(1 + 2)",
+		},
+		{
+			"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5);",
+			"((10 - 5) - (2 + 2))",
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) { unquote(consequence) } else { unquote(alternative) })
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if ((!(10 > 5))) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			"let twice = macro(x) { quote(unquote(x) + unquote(x)) }; let m = macro(y) { quote(twice(unquote(y))) }; m(f(1));",
+			"(f(1) + f(1))",
+		},
+		{
+			"let same = macro(x) { quote(x) }; fn f(x) { same(x * 2) }",
+			"fn f(x) { x }",
+		},
+		{
+			"let m = macro(a) { quote(unquote(a)) }; g(m(1), m(2))",
+			"g(1, 2)",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		macros := DefineMacros(program)
+		expanded, errors := ExpandMacros(program, macros)
+		if len(errors) != 0 {
+			t.Errorf("unexpected errors for %q: %v", tt.input, errors)
+			continue
+		}
+		if expanded.String() != tt.expected {
+			t.Errorf("wrong expansion of %q.\nwant = %q\ngot  = %q", tt.input, tt.expected, expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosCopiesArguments(t *testing.T) {
+	program := parse(t, "let twice = macro(x) { quote(unquote(x) + unquote(x)) }; twice(a)")
+	expanded, errors := ExpandMacros(program, DefineMacros(program))
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+	infix := expanded.(*ast.Program).Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	if infix.Left == infix.Right {
+		t.Errorf("both operands are the same node")
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let m = macro(a, b) { quote(a) }; m(1)", "wrong number of arguments to macro m: want 2, got 1"},
+		{"let m = macro(a) { a }; m(1)", "macro m must consist of a single quote(...) expression"},
+		{"let m = macro(a) { let b = a; quote(b) }; m(1)", "macro m must consist of a single quote(...) expression"},
+		{"let m = macro(a) { quote(unquote(a, a)) }; m(1)", "unquote in macro m takes exactly one argument, got 2"},
+		{"let m = macro(a) { quote(m(a)) }; m(1)", "macro expansion did not finish after 100 rounds"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		_, errors := ExpandMacros(program, DefineMacros(program))
+		if len(errors) == 0 {
+			t.Errorf("expected errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
@@ -8,6 +8,15 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "parse" {
+		os.Exit(runParse(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doc" {
+		os.Exit(runDoc(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/token"
+)
+
+// This file holds the supported API for embedders that want to add their own syntax
+// to the Pratt parser without forking it. A typical extension registers the token
+// with the lexer, a parse function and a precedence with the parser:
+//
+//	l := lexer.New(input)
+//	l.RegisterKeyword("in", IN)
+//	p := parser.New(l)
+//	p.RegisterInfix(IN, p.ParseInfixExpression)
+//	p.SetPrecedence(IN, parser.LESSGREATER, parser.LeftAssoc)
+//
+// Registrations are per parser and never leak into other parsers.
+
+// RegisterPrefix registers fn to be called whenever tokenType starts an expression.
+// It replaces any function previously registered for the same token type.
+func (p *Parser) RegisterPrefix(tokenType token.TokenType, fn PrefixParseFn) {
+	p.registerPrefix(tokenType, fn)
+}
+
+// RegisterInfix registers fn to be called whenever tokenType follows a complete
+// expression and binds tighter than the surrounding operator.
+func (p *Parser) RegisterInfix(tokenType token.TokenType, fn InfixParseFn) {
+	p.registerInfix(tokenType, fn)
+}
+
+// SetPrecedence sets the binding power and associativity of tokenType for this parser only.
+// Precedences follow the constants LOWEST through INDEX; an infix function is only
+// invoked for tokens with a precedence above LOWEST.
+func (p *Parser) SetPrecedence(tokenType token.TokenType, precedence int, assoc Associativity) {
+	p.precedences[tokenType] = precedence
+	if assoc == LeftAssoc {
+		delete(p.associativities, tokenType)
+		return
+	}
+	p.associativities[tokenType] = assoc
+}
+
+// CurToken returns the token currently under examination
+func (p *Parser) CurToken() token.Token {
+	return p.curToken
+}
+
+// PeekToken returns the token after CurToken
+func (p *Parser) PeekToken() token.Token {
+	return p.peekToken
+}
+
+// NextToken advances CurToken and PeekToken by one token
+func (p *Parser) NextToken() {
+	p.nextToken()
+}
+
+// ExpectPeek advances to the next token if it has type t, otherwise it records an error and returns false
+func (p *Parser) ExpectPeek(t token.TokenType) bool {
+	return p.expectPeek(t)
+}
+
+// ParseExpression parses an expression starting at CurToken, consuming operators
+// that bind tighter than precedence
+func (p *Parser) ParseExpression(precedence int) ast.Expression {
+	return p.parseExpression(precedence)
+}
+
+// ParseInfixExpression is the infix function used for the built-in binary operators.
+// Registering it for a new token type produces an *ast.InfixExpression using the
+// token's literal as the operator.
+func (p *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
+	return p.parseInfixExpression(left)
+}
+
+// Errorf records a parse error that will be reported by Errors
+func (p *Parser) Errorf(format string, args ...interface{}) {
+	p.errors = append(p.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/token"
+)
+
+// Edit replaces Removed bytes of the source at Offset with Inserted
+type Edit struct {
+	Offset   int
+	Removed  int
+	Inserted string
+}
+
+// Document is a parsed source text that can be updated with edits without
+// reparsing all of it, which is what editor integrations do on every keystroke.
+type Document struct {
+	Source  string
+	Program *ast.Program
+	stmts   []*parsedStatement // every parsed statement, including the ones left out of Program
+}
+
+// ParseDocument parses source from scratch
+func ParseDocument(source string) *Document {
+	p := New(lexer.New(source))
+	return newDocument(source, p.parseStatements(nil))
+}
+
+func newDocument(source string, stmts []*parsedStatement) *Document {
+	d := &Document{Source: source, Program: &ast.Program{Statements: []ast.Statement{}}, stmts: stmts}
+	for _, stmt := range stmts {
+		if stmt.node != nil {
+			d.Program.Statements = append(d.Program.Statements, stmt.node)
+		}
+	}
+	return d
+}
+
+// Errors returns the parser errors of the whole document
+func (d *Document) Errors() []string {
+	errors := []string{}
+	for _, stmt := range d.stmts {
+		errors = append(errors, stmt.errors...)
+	}
+	return append(errors, duplicateFunctions(d.Program.Statements)...)
+}
+
+// Apply returns the document that results from applying e to d. Only the statements
+// touched by the edit are lexed and parsed again; the statements before and after them
+// are reused from d, with the token positions of the latter shifted in place. d must not
+// be used after Apply returns successfully.
+func (d *Document) Apply(e Edit) (*Document, error) {
+	if e.Offset < 0 || e.Removed < 0 || e.Offset+e.Removed > len(d.Source) {
+		return nil, fmt.Errorf("edit at %d removing %d bytes is outside of the %d byte document", e.Offset, e.Removed, len(d.Source))
+	}
+	source := d.Source[:e.Offset] + e.Inserted + d.Source[e.Offset+e.Removed:]
+	if d.declaresOperators() {
+		// operator declarations change how everything after them is lexed
+		return ParseDocument(source), nil
+	}
+
+	// A statement can be kept when everything it was parsed from, up to and including
+	// the lookahead after its peek token, lies before the edit.
+	keep := 0
+	for keep+1 < len(d.stmts) && d.stmts[keep].lookahead < e.Offset {
+		keep++
+	}
+	// without a kept statement the edit may add tokens in front of the first statement
+	start := 0
+	if keep > 0 {
+		start = d.stmts[keep].start
+	}
+
+	// Statements that start after the removed text parse the same way as long as the
+	// new parse reaches a statement boundary at their shifted position.
+	delta := len(e.Inserted) - e.Removed
+	resume := map[int]int{}
+	for i := keep; i < len(d.stmts); i++ {
+		if d.stmts[i].start >= e.Offset+e.Removed {
+			resume[d.stmts[i].start+delta] = i
+		}
+	}
+	reused := len(d.stmts)
+	p := New(lexer.New(source[start:]))
+	parsed := p.parseStatements(func(pos int) bool {
+		i, ok := resume[start+pos]
+		if ok {
+			reused = i
+		}
+		return ok
+	})
+
+	stmts := append([]*parsedStatement{}, d.stmts[:keep]...)
+	for _, stmt := range parsed {
+		if _, ok := stmt.node.(*ast.InfixStatement); ok {
+			return ParseDocument(source), nil
+		}
+		shiftStatement(stmt, start)
+		stmts = append(stmts, stmt)
+	}
+	for _, stmt := range d.stmts[reused:] {
+		shiftStatement(stmt, delta)
+		stmts = append(stmts, stmt)
+	}
+	return newDocument(source, stmts), nil
+}
+
+func (d *Document) declaresOperators() bool {
+	for _, stmt := range d.stmts {
+		if _, ok := stmt.node.(*ast.InfixStatement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func shiftStatement(stmt *parsedStatement, delta int) {
+	if delta == 0 {
+		return
+	}
+	stmt.start += delta
+	stmt.end += delta
+	stmt.lookahead += delta
+	walkTokens(stmt.node, func(tok *token.Token) {
+		tok.Pos += delta
+		tok.End += delta
+	})
+}
+
+// walkTokens calls fn for every token stored in node and its children
+func walkTokens(node ast.Node, fn func(*token.Token)) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			fn(&n.Token)
+		case *ast.ReturnStatement:
+			fn(&n.Token)
+		case *ast.ExpressionStatement:
+			fn(&n.Token)
+		case *ast.BlockStatement:
+			fn(&n.Token)
+			fn(&n.Rbrace)
+		case *ast.FunctionStatement:
+			fn(&n.Token)
+		case *ast.InfixStatement:
+			fn(&n.Token)
+		case *ast.StructStatement:
+			fn(&n.Token)
+			fn(&n.Rbrace)
+		case *ast.WhileStatement:
+			fn(&n.Token)
+		case *ast.ForStatement:
+			fn(&n.Token)
+		case *ast.BreakStatement:
+			fn(&n.Token)
+		case *ast.ContinueStatement:
+			fn(&n.Token)
+		case *ast.Identifier:
+			fn(&n.Token)
+		case *ast.IntegerLiteral:
+			fn(&n.Token)
+		case *ast.StringLiteral:
+			fn(&n.Token)
+		case *ast.Boolean:
+			fn(&n.Token)
+		case *ast.NullLiteral:
+			fn(&n.Token)
+		case *ast.PrefixExpression:
+			fn(&n.Token)
+		case *ast.InfixExpression:
+			fn(&n.Token)
+		case *ast.IfExpression:
+			fn(&n.Token)
+		case *ast.MacroLiteral:
+			fn(&n.Token)
+		case *ast.FunctionLiteral:
+			fn(&n.Token)
+		case *ast.CallExpression:
+			fn(&n.Token)
+			fn(&n.Rparen)
+		case *ast.AssignExpression:
+			fn(&n.Token)
+		case *ast.IndexExpression:
+			fn(&n.Token)
+			fn(&n.Rbracket)
+		case *ast.MemberExpression:
+			fn(&n.Token)
+		case *ast.MatchExpression:
+			fn(&n.Token)
+			fn(&n.Rbrace)
+		case *ast.MatchArm:
+			fn(&n.Token)
+		case *ast.WildcardPattern:
+			fn(&n.Token)
+		case *ast.ArrayPattern:
+			fn(&n.Token)
+			fn(&n.Rbracket)
+		case *ast.HashPattern:
+			fn(&n.Token)
+			fn(&n.Rbrace)
+		case *ast.ParenExpression:
+			fn(&n.Token)
+			fn(&n.Rparen)
+		}
+		return true
+	})
+}
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BentleyOph/monke/ast"
+	"github.com/BentleyOph/monke/token"
+)
+
+const incrementalSource = `let five = 5;
+let ten = 10;
+
+let add = fn(x, y) {
+	x + y; // sum
+};
+
+let result = add(five, ten);
+if (result < 20) { result } else { 20 }
+while (result > 0) { result -= 1; }
+"done"
+fn twice(f, x = 1) { f(f(x)) }
+`
+
+func TestDocumentApply(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		edit   Edit
+		reused []int // statements of the old document that must be reused
+	}{
+		{"change literal", incrementalSource, Edit{Offset: 11, Removed: 1, Inserted: "50"}, []int{2, 3, 4, 5, 6}},
+		{"rename last statement", incrementalSource, Edit{Offset: strings.Index(incrementalSource, `"done"`), Removed: 6, Inserted: `"finished"`}, []int{0, 1, 2, 3, 4}},
+		{"edit function body", incrementalSource, Edit{Offset: strings.Index(incrementalSource, "x + y"), Removed: 5, Inserted: "x * y * 2"}, []int{0, 4, 5, 6}},
+		{"insert statement", incrementalSource, Edit{Offset: strings.Index(incrementalSource, "let result"), Inserted: "let z = 3;\n"}, []int{0, 1, 3, 4, 5, 6}},
+		{"delete statement", incrementalSource, Edit{Offset: strings.Index(incrementalSource, "let ten"), Removed: len("let ten = 10;\n")}, []int{3, 4, 5, 6}},
+		{"insert at start", incrementalSource, Edit{Offset: 0, Inserted: "// header\n"}, []int{0, 1, 2, 3, 4, 5, 6}},
+		{"extend expression", "let a = 1\nlet b = 2", Edit{Offset: 9, Inserted: " + 3"}, nil},
+		{"join statements", "a; b; c; d", Edit{Offset: 4, Removed: 2, Inserted: " +"}, []int{3}},
+		{"break syntax", incrementalSource, Edit{Offset: strings.Index(incrementalSource, "x + y"), Removed: 2}, []int{0, 4, 5, 6}},
+		{"fix syntax", "let = 5; let y = 6;", Edit{Offset: 4, Inserted: "x "}, nil},
+		{"append", "let a = 1;", Edit{Offset: 10, Inserted: " a"}, nil},
+		{"empty document", "", Edit{Offset: 0, Inserted: "let a = 1;"}, nil},
+		{"delete everything", "let a = 1; a", Edit{Offset: 0, Removed: 12}, nil},
+		{"edit doc comment", "/// one\nlet a = 1;\nlet b = 2;\n/// two\nfn c() {}", Edit{Offset: 34, Removed: 3, Inserted: "three"}, []int{0}},
+		{"add doc comment", "let a = 1;\nlet b = 2;\nlet c = 3;\nlet d = 4;", Edit{Offset: 22, Inserted: "/// c\n"}, []int{0, 3}},
+		{"operator declaration", "infix 6 left <+> = add; a <+> b", Edit{Offset: 30, Removed: 1, Inserted: "c"}, nil},
+		{"shift struct", "/// P.\nstruct P { x, y }\np.x = 1;", Edit{Offset: 0, Inserted: "let a = 1;\n"}, []int{0, 1}},
+		{"edit after peek token", "let [c,x..d] = e;", Edit{Offset: 10, Removed: 1, Inserted: "."}, nil},
+		{"edit after failed statement", "let = ..x; y", Edit{Offset: 8, Removed: 1, Inserted: "."}, nil},
+	}
+
+	for _, tt := range tests {
+		old := ParseDocument(tt.source)
+		oldStmts := append([]ast.Statement{}, old.Program.Statements...)
+
+		doc, err := old.Apply(tt.edit)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tt.name, err)
+		}
+		source := tt.source[:tt.edit.Offset] + tt.edit.Inserted + tt.source[tt.edit.Offset+tt.edit.Removed:]
+		if doc.Source != source {
+			t.Fatalf("%s: doc.Source wrong. got = %q", tt.name, doc.Source)
+		}
+		checkSameAsFullParse(t, tt.name, doc)
+
+		for _, i := range tt.reused {
+			if !containsStatement(doc.Program.Statements, oldStmts[i]) {
+				t.Errorf("%s: statement %d (%s) was not reused", tt.name, i, oldStmts[i])
+			}
+		}
+	}
+}
+
+func TestDocumentApplyOutOfRange(t *testing.T) {
+	doc := ParseDocument("let a = 1;")
+	for _, e := range []Edit{{Offset: -1}, {Offset: 11}, {Offset: 5, Removed: 6}, {Offset: 2, Removed: -1}} {
+		if _, err := doc.Apply(e); err == nil {
+			t.Errorf("expected an error for %+v", e)
+		}
+	}
+}
+
+const patternSource = `let [a, ...rest] = xs;
+let {"k": v} = h;
+match (a) {
+	[first, ...more] if first > 0 => first,
+	_ => x?.y ?? z?[0],
+}
+let f = fn(a, b = 2, ...c) { a.b.c };
+[1, 2][0];
+`
+
+func TestDocumentApplyRandomEdits(t *testing.T) {
+	fragments := []string{"", " ", "\n", ";", "x", "1", "+", "*", "(", ")", "{", "}", "=", "let ", "fn", "fn f", "if ", "// c\n", "/// d\n", `"`, ",", "return "}
+	applyRandomEdits(t, incrementalSource, fragments, 2000, 1)
+}
+
+func TestDocumentApplyRandomPatternEdits(t *testing.T) {
+	fragments := []string{"", " ", "\n", ";", "x", ".", "..", "...", "[", "]", "{", "}", "(", ")", ",", ":", "?", "?.", "??", "?[", "=", "=>", "_", "let ", "match ", `"k"`}
+	applyRandomEdits(t, patternSource, fragments, 20000, 2)
+}
+
+// applyRandomEdits applies random edits made of fragments to source and checks each
+// result against a full parse of the edited source
+func applyRandomEdits(t *testing.T, source string, fragments []string, n int, seed int64) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(seed))
+
+	doc := ParseDocument(source)
+	for i := 0; i < n; i++ {
+		offset := rng.Intn(len(doc.Source) + 1)
+		removed := rng.Intn(len(doc.Source)-offset+1) % 4
+		edit := Edit{Offset: offset, Removed: removed, Inserted: fragments[rng.Intn(len(fragments))]}
+
+		next, err := doc.Apply(edit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !checkSameAsFullParse(t, edit.Inserted, next) {
+			t.Fatalf("edit %d (%+v) of %q diverged from a full parse", i, edit, doc.Source)
+		}
+		doc = next
+		if len(doc.Source) > 2*len(source) || len(doc.Source) < len(source)/2 {
+			doc = ParseDocument(source)
+		}
+	}
+}
+
+// checkSameAsFullParse compares doc with a fresh parse of its source, including token positions
+func checkSameAsFullParse(t *testing.T, name string, doc *Document) bool {
+	t.Helper()
+	full := ParseDocument(doc.Source)
+	if doc.Program.String() != full.Program.String() || !ast.Equal(doc.Program, full.Program) {
+		t.Errorf("%s: program wrong. want = %q, got = %q", name, full.Program.String(), doc.Program.String())
+		return false
+	}
+	if !reflect.DeepEqual(docComments(doc), docComments(full)) {
+		t.Errorf("%s: doc comments wrong. want = %q, got = %q", name, docComments(full), docComments(doc))
+		return false
+	}
+	if !reflect.DeepEqual(doc.Errors(), full.Errors()) {
+		t.Errorf("%s: errors wrong. want = %q, got = %q", name, full.Errors(), doc.Errors())
+		return false
+	}
+	if !reflect.DeepEqual(tokenPositions(doc), tokenPositions(full)) {
+		t.Errorf("%s: token positions differ from a full parse", name)
+		return false
+	}
+	return true
+}
+
+func docComments(doc *Document) []string {
+	docs := []string{}
+	for _, s := range doc.Program.Statements {
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			docs = append(docs, s.Doc)
+		case *ast.FunctionStatement:
+			docs = append(docs, s.Doc)
+		case *ast.StructStatement:
+			docs = append(docs, s.Doc)
+		}
+	}
+	return docs
+}
+
+func tokenPositions(doc *Document) [][2]int {
+	positions := [][2]int{}
+	for _, stmt := range doc.stmts {
+		positions = append(positions, [2]int{stmt.start, stmt.end})
+		walkTokens(stmt.node, func(tok *token.Token) {
+			positions = append(positions, [2]int{tok.Pos, tok.End})
+		})
+	}
+	return positions
+}
+
+func containsStatement(stmts []ast.Statement, stmt ast.Statement) bool {
+	for _, s := range stmts {
+		if s == stmt {
+			return true
+		}
+	}
+	return false
+}
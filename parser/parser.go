@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/BentleyOph/monke/ast"
 	"github.com/BentleyOph/monke/lexer"
@@ -15,38 +16,99 @@ type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token // points to the current token
 	peekToken token.Token // points to the next token
-	prefixParseFns map[token.TokenType]prefixParseFn //map of functions that parse prefix expressions
-	infixParseFns map[token.TokenType]infixParseFn //map of functions that parse infix expressions
+	prefixParseFns map[token.TokenType]PrefixParseFn //map of functions that parse prefix expressions
+	infixParseFns map[token.TokenType]InfixParseFn //map of functions that parse infix expressions
+	precedences    map[token.TokenType]int           // per-parser copy of the precedence table
+	associativities map[token.TokenType]Associativity // operators that do not group to the left
+	primed         bool                              // whether curToken and peekToken have been read
+	loopDepth      int                               // number of loops enclosing curToken within the current function
+	depth          int                               // number of expressions and blocks currently being parsed
+	maxDepth       int                               // limit on depth, see SetMaxDepth
+	tooDeep        bool                              // whether maxDepth was exceeded and the rest of the input skipped
+	curDoc         []token.Token                     // the /// doc comments right before curToken
+	peekDoc        []token.Token                     // the /// doc comments right before peekToken
 
 	errors    []string
 }
+
+// PrefixParseFn and InfixParseFn are the signatures of the functions that can be
+// registered on a Parser. A prefix function is called with curToken on the token it
+// was registered for; an infix function additionally receives the already parsed left operand.
 type (
-	prefixParseFn func() ast.Expression //parse functions for prefix expressions
-	infixParseFn func(ast.Expression) ast.Expression //parse functions for infix expressions
+	PrefixParseFn func() ast.Expression //parse functions for prefix expressions
+	InfixParseFn func(ast.Expression) ast.Expression //parse functions for infix expressions
+)
+
+// Associativity decides how operators of equal precedence are grouped
+type Associativity int
+
+const (
+	LeftAssoc  Associativity = iota // a - b - c parses as ((a - b) - c)
+	RightAssoc                      // a ** b ** c parses as (a ** (b ** c))
 )
 
 const(
 	_ int = iota //assigns the zero value to the first constant in the group
 	LOWEST //lowest precedence
+	ASSIGNMENT // x = y or x += y
 	EQUALS // ==
 	LESSGREATER // > or <
 	SUM // +
 	PRODUCT // *
 	PREFIX // -X or !X
+	POWER // X ** Y
 	CALL // myFunction(X)
+	INDEX // array[index]
 )
 
+// DefaultMaxDepth is the nesting depth a new Parser accepts. It is far beyond anything
+// written by hand and far below what exhausts the Go stack.
+const DefaultMaxDepth = 1000
+
+
+// infixLevels maps the precedences written in infix declarations onto the constants above.
+// Scripts keep their meaning when a level is added to the parser, such as ASSIGNMENT.
+var infixLevels = map[int]int{
+	2: EQUALS,
+	3: LESSGREATER,
+	4: SUM,
+	5: PRODUCT,
+	6: PREFIX,
+}
+
+const minInfixLevel, maxInfixLevel = 2, 6
 
 var precedences = map[token.TokenType]int{ //map of precedences for each token type
 	token.EQ : EQUALS,
 	token.NOT_EQ : EQUALS,
 	token.LT : LESSGREATER,
 	token.GT : LESSGREATER,
+	token.NULLISH: LESSGREATER, // a ?? 0 < 5 compares the result, a ?? b + 1 adds to the fallback
 	token.PLUS: SUM,
 	token.MINUS: SUM,
 	token.SLASH: PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.POWER: POWER,
 	token.LPAREN: CALL,
+	token.LBRACKET: INDEX,
+	token.OPTIONAL_LBRACKET: INDEX,
+	token.OPTIONAL_DOT: INDEX,
+	token.DOT: INDEX,
+	token.ASSIGN: ASSIGNMENT,
+	token.PLUS_ASSIGN: ASSIGNMENT,
+	token.MINUS_ASSIGN: ASSIGNMENT,
+	token.ASTERISK_ASSIGN: ASSIGNMENT,
+	token.SLASH_ASSIGN: ASSIGNMENT,
+}
+
+// operators that group to the right; everything missing from this map is left-associative
+var associativities = map[token.TokenType]Associativity{
+	token.POWER: RightAssoc,
+	token.ASSIGN: RightAssoc,
+	token.PLUS_ASSIGN: RightAssoc,
+	token.MINUS_ASSIGN: RightAssoc,
+	token.ASTERISK_ASSIGN: RightAssoc,
+	token.SLASH_ASSIGN: RightAssoc,
 }
 
 
@@ -55,80 +117,254 @@ func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l: l,
 		errors: []string{},
+		maxDepth: DefaultMaxDepth,
+		precedences: make(map[token.TokenType]int),
+		associativities: make(map[token.TokenType]Associativity),
 	}
-	//Read two tokens so curToken and peekToken are both set
-	p.nextToken()
-	p.nextToken()
-	p.prefixParseFns = make (map[token.TokenType]prefixParseFn) //initialize the map
+	for t, prec := range precedences {
+		p.precedences[t] = prec
+	}
+	for t, assoc := range associativities {
+		p.associativities[t] = assoc
+	}
+	// tokens are read lazily by ParseProgram so that keywords and operators
+	// registered after New still apply to the first two tokens
+	p.prefixParseFns = make (map[token.TokenType]PrefixParseFn) //initialize the map
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT,p.parseIntegerLiteral)
 	p.registerPrefix(token.BANG,p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS,p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE,p.parseBoolean)
 	p.registerPrefix(token.FALSE,p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN,p.parseGroupedExpression)
 	p.registerPrefix(token.IF,p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION,p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING,p.parseStringLiteral)
-	p.infixParseFns = make (map[token.TokenType]infixParseFn)
+	p.infixParseFns = make (map[token.TokenType]InfixParseFn)
 	p.registerInfix(token.PLUS,p.parseInfixExpression)
 	p.registerInfix(token.MINUS,p.parseInfixExpression)
 	p.registerInfix(token.SLASH,p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK,p.parseInfixExpression)
+	p.registerInfix(token.POWER,p.parseInfixExpression)
 	p.registerInfix(token.EQ,p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ,p.parseInfixExpression)
 	p.registerInfix(token.LT,p.parseInfixExpression)
 	p.registerInfix(token.GT,p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN,p.parseCallExpression)
+	p.registerInfix(token.LBRACKET,p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL_DOT, p.parseMemberExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN,p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN,p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN,p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN,p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN,p.parseAssignExpression)
 	return p
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curDoc, p.peekDoc = p.peekDoc, nil
 	p.peekToken = p.l.NextToken()
+	// doc comments are not part of the grammar; they are kept for the declaration that follows them
+	for p.peekToken.Type == token.DOC {
+		p.peekDoc = append(p.peekDoc, p.peekToken)
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// docText returns the text of the doc comments before curToken, one line per comment
+// with the space after the slashes removed, or "" if there are none
+func (p *Parser) docText() string {
+	lines := make([]string, len(p.curDoc))
+	for i, doc := range p.curDoc {
+		lines[i] = strings.TrimRight(strings.TrimPrefix(doc.Literal, " "), " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prime reads two tokens so curToken and peekToken are both set
+func (p *Parser) prime() {
+	if p.primed {
+		return
+	}
+	p.primed = true
+	p.nextToken()
+	p.nextToken()
+}
+
+// SetMaxDepth sets how deeply expressions and blocks may nest before parsing fails.
+// Input that nests deeper is reported with a single error and not parsed any further,
+// so that untrusted scripts cannot overflow the stack.
+func (p *Parser) SetMaxDepth(depth int) {
+	p.maxDepth = depth
+}
+
+// enter is called before parsing a nested expression or block and returns false when
+// that would exceed maxDepth. Every call must be paired with a call to leave.
+func (p *Parser) enter() bool {
+	return p.deepen(1)
 }
 
+// deepen adds levels to the current depth and reports an error like enter when that
+// exceeds maxDepth. The caller takes the levels off again once they are finished.
+func (p *Parser) deepen(levels int) bool {
+	p.depth += levels
+	if p.depth <= p.maxDepth {
+		return true
+	}
+	if !p.tooDeep {
+		p.tooDeep = true
+		msg := fmt.Sprintf("maximum nesting depth of %d exceeded", p.maxDepth)
+		p.errors = append(p.errors, msg)
+		// everything up to here is unfinished, so there is nothing left to recover
+		for !p.curTokenIs(token.EOF) {
+			p.nextToken()
+		}
+	}
+	return false
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
+// ParseProgram never panics and never returns partially parsed nodes: a statement that
+// fails to parse is left out of the program and reported through Errors instead.
 func (p *Parser) ParseProgram() *ast.Program { //returns the root node of our AST
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
-	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-			program.Statements = append(program.Statements, stmt)
-		p.nextToken() //repeatedly call nextToken to advance the curToken and peekToken pointers
-
+	for _, stmt := range p.parseStatements(nil) {
+		if stmt.node != nil {
+			program.Statements = append(program.Statements, stmt.node)
+		}
 	}
+	p.errors = append(p.errors, duplicateFunctions(program.Statements)...)
 	return program
 
 }
 
+// parsedStatement is a top-level statement together with the source range and errors it was parsed from
+type parsedStatement struct {
+	node   ast.Statement // nil when the statement failed to parse
+	start  int // offset of the first token, or of the doc comments before it
+	end    int // offset just past the last token, including a trailing semicolon
+	// lookahead is the offset of the last character the statement was parsed from. It lies
+	// past end because errors can name the peek token, and lexing a token can look one
+	// character past it, as '.' does to tell . from ...
+	lookahead int
+	errors    []string
+}
+
+// parseStatements parses top-level statements until EOF, or until stop returns true
+// for the offset of the token that would start the next statement
+func (p *Parser) parseStatements(stop func(pos int) bool) []*parsedStatement {
+	p.prime()
+	stmts := []*parsedStatement{}
+	for p.curToken.Type != token.EOF {
+		start := p.curToken.Pos
+		if len(p.curDoc) > 0 {
+			start = p.curDoc[0].Pos
+		}
+		if stop != nil && stop(start) {
+			break
+		}
+		stmt := &parsedStatement{start: start}
+		errors := len(p.errors)
+		stmt.node = p.parseStatement()
+		stmt.end = p.curToken.End
+		stmt.lookahead = p.peekToken.End + 1
+		stmt.errors = p.errors[errors:len(p.errors):len(p.errors)]
+		stmts = append(stmts, stmt)
+		p.nextToken() //repeatedly call nextToken to advance the curToken and peekToken pointers
+	}
+	return stmts
+}
+
 func (p *Parser) parseIdentifier() ast.Expression{
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseStatement returns nil when the statement failed to parse
 func (p *Parser) parseStatement() ast.Statement {
+	// the concrete parse functions return typed nil pointers, which must not end up in an ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
-		return p.ParseReturnStatement()
-	default:
-		return p.parseExpressionStatement()
+		if stmt := p.ParseReturnStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.INFIX:
+		if stmt := p.parseInfixStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.STRUCT:
+		if stmt := p.parseStructStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FUNCTION:
+		// without a name, fn starts a function literal in an expression statement
+		if p.peekTokenIs(token.IDENT) {
+			if stmt := p.parseFunctionStatement(); stmt != nil {
+				return stmt
+			}
+			return nil
+		}
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
 	}
+	if stmt := p.parseExpressionStatement(); stmt != nil {
+		return stmt
+	}
+	return nil
 }
 
+// parseLetStatement parses let <name> = <value> and the destructuring
+// let [<pattern>, ...] = <value> and let {<key>: <pattern>, ...} = <value>
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
-	// enforce that the next token is an identifier
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.docText()}
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		// enforce that the next token is an identifier
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	// let f = fn() { ... } names the function f
+	if lit, ok := stmt.Value.(*ast.FunctionLiteral); ok && lit.Name == "" && stmt.Name != nil {
+		lit.Name = stmt.Name.Value
+	}
 	if p.peekTokenIs(token.SEMICOLON){
 		p.nextToken()
 	
@@ -136,6 +372,90 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parseInfixStatement parses infix <precedence> <left|right> <operator> = <expression>;
+// and makes the operator available to the lexer and parser for the rest of the input.
+func (p *Parser) parseInfixStatement() *ast.InfixStatement {
+	stmt := &ast.InfixStatement{Token: p.curToken}
+	if !p.expectPeek(token.INT) {
+		return nil
+	}
+	level, err := strconv.Atoi(p.curToken.Literal)
+	precedence, ok := infixLevels[level]
+	if err != nil || !ok {
+		msg := fmt.Sprintf("infix precedence must be between %d and %d, got %s", minInfixLevel, maxInfixLevel, p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	stmt.Precedence = level
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	assoc := LeftAssoc
+	switch p.curToken.Literal {
+	case "left":
+	case "right":
+		assoc = RightAssoc
+	default:
+		msg := fmt.Sprintf("infix associativity must be left or right, got %s", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	stmt.Associativity = p.curToken.Literal
+
+	// peekToken holds the first piece of the operator; glue on the symbols that directly follow it
+	// before the lexer reads any further
+	symbol := p.peekToken.Literal + p.l.ReadSymbol()
+	if !isOperatorSymbol(symbol) {
+		msg := fmt.Sprintf("expected operator symbol, got %s", symbol)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	tokenType := token.TokenType(symbol)
+	if _, ok := p.infixParseFns[tokenType]; ok || symbol == p.peekToken.Literal && p.peekToken.Type != token.ILLEGAL {
+		msg := fmt.Sprintf("operator %s is already defined", symbol)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	if hidden, ok := lexer.Hides(symbol); ok {
+		msg := fmt.Sprintf("operator %s would hide the built-in operator %s", symbol, hidden)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	p.peekToken = token.Token{Type: tokenType, Literal: symbol, Pos: p.peekToken.Pos, End: p.peekToken.Pos + len(symbol)}
+	stmt.Operator = symbol
+
+	p.l.RegisterOperator(symbol, tokenType)
+	p.registerInfix(tokenType, p.parseInfixExpression)
+	p.SetPrecedence(tokenType, precedence, assoc)
+
+	p.nextToken()
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Function = p.parseExpression(LOWEST)
+	if stmt.Function == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func isOperatorSymbol(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !lexer.IsOperatorChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -168,6 +488,9 @@ func (p *Parser)ParseReturnStatement() *ast.ReturnStatement{
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if stmt.ReturnValue == nil {
+		return nil
+	}
 	if p.peekTokenIs(token.SEMICOLON){
 		p.nextToken()
 	}
@@ -175,11 +498,11 @@ func (p *Parser)ParseReturnStatement() *ast.ReturnStatement{
 }
 
 //register a prefix parse function for a token type
-func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn){ 
+func (p *Parser) registerPrefix(tokenType token.TokenType, fn PrefixParseFn){ 
 	p.prefixParseFns[tokenType] = fn
 }
 
-func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn){
+func (p *Parser) registerInfix(tokenType token.TokenType, fn InfixParseFn){
 	p.infixParseFns[tokenType] = fn
 }
 
@@ -189,6 +512,9 @@ func(p *Parser) parseExpressionStatement() *ast.ExpressionStatement{
 	// defer untrace(trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
+	if stmt.Expression == nil {
+		return nil
+	}
 	if p.peekTokenIs(token.SEMICOLON){
 		p.nextToken()
 	}
@@ -201,14 +527,27 @@ func(p *Parser) parseExpressionStatement() *ast.ExpressionStatement{
 // It returns the resulting expression.
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// defer untrace(trace("parseExpression"))
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
+	return p.parseOperation(precedence)
+}
+
+// parseOperation is parseExpression without the depth check, for callers that have
+// already accounted for the level the expression is parsed at.
+func (p *Parser) parseOperation(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type] // check if a prefix parse function exists for the current token type
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
-	leftExp := prefix()
+	return p.parseInfixes(prefix(), precedence)
+}
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+// parseInfixes applies every infix operator that binds tighter than precedence to leftExp.
+func (p *Parser) parseInfixes(leftExp ast.Expression, precedence int) ast.Expression {
+	for leftExp != nil && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
@@ -229,6 +568,9 @@ func (p *Parser) parsePrefixExpression() ast.Expression{
 	}
 	p.nextToken()
 	expression.Right = p.parseExpression(PREFIX)
+	if expression.Right == nil {
+		return nil
+	}
 	return expression
 }
 
@@ -261,14 +603,14 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+	if p, ok := p.precedences[p.peekToken.Type]; ok {
 		return p 
 	}
 	return LOWEST
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type];ok{
+	if p, ok := p.precedences[p.curToken.Type];ok{
 		return p
 	}
 	return LOWEST
@@ -276,6 +618,17 @@ func (p *Parser) curPrecedence() int {
 
 
 
+// curRightPrecedence returns the precedence to parse the right operand of the current operator with
+func (p *Parser) curRightPrecedence() int {
+	precedence := p.curPrecedence()
+	if p.associativities[p.curToken.Type] == RightAssoc {
+		// binding the right operand one level lower lets an operator of the same precedence be absorbed into it
+		precedence--
+	}
+	return precedence
+}
+
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression{
 	// defer untrace(trace("parseInfixExpression"))
 	expression := &ast.InfixExpression{
@@ -283,26 +636,122 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression{
 		Operator: p.curToken.Literal,
 		Left: left,
 	}
-	precedence := p.curPrecedence()
+	precedence := p.curRightPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
+	if expression.Right == nil {
+		return nil
+	}
 	return expression 
 }
 
 
+// parseAssignExpression parses x = y and the compound forms x += y, x -= y, x *= y and x /= y.
+// Only identifiers, index expressions and member expressions can be assigned to, and
+// a?[i] and a?.b cannot.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	valid := false
+	switch t := ast.Unparen(target).(type) {
+	case *ast.Identifier:
+		valid = true
+	case *ast.IndexExpression:
+		valid = !t.Optional
+	case *ast.MemberExpression:
+		valid = !t.Optional
+	}
+	if !valid {
+		msg := fmt.Sprintf("invalid assignment target %s", target)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+	precedence := p.curRightPrecedence()
+	p.nextToken()
+	expression.Value = p.parseExpression(precedence)
+	if expression.Value == nil {
+		return nil
+	}
+	return expression
+}
+
+// parseIndexExpression parses a[i] and the optional a?[i]
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left, Optional: p.curTokenIs(token.OPTIONAL_LBRACKET)}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if exp.Index == nil || !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+	return exp
+}
+
+
+// parseMemberExpression parses a.b and the optional a?.b
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object, Optional: p.curTokenIs(token.OPTIONAL_DOT)}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseBoolean() ast.Expression{
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+// parseGroupedExpression reads a whole run of opening parens in a loop, and a paren shares
+// its depth with the expression it wraps. String writes every operation in parens of its
+// own, so charging for those would make printed programs nest deeper than their source.
+// Only parens around anything else, as in ((x)), count against maxDepth.
 func (p *Parser) parseGroupedExpression() ast.Expression{
-	p.nextToken()
-	exp := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN){
+	var open []*ast.ParenExpression
+	for p.curTokenIs(token.LPAREN) {
+		open = append(open, &ast.ParenExpression{Token: p.curToken})
+		p.nextToken()
+	}
+	exp := p.parseOperation(LOWEST)
+	levels := 0
+	for i := len(open) - 1; i >= 0; i-- {
+		if exp == nil || !p.expectPeek(token.RPAREN){
+			return nil
+		}
+		if !parenthesized(exp) {
+			levels++
+		}
+		open[i].Expression = exp
+		open[i].Rparen = p.curToken
+		exp = open[i]
+		if i > 0 {
+			exp = p.parseInfixes(exp, LOWEST)
+		}
+	}
+	defer func() { p.depth -= levels }()
+	if !p.deepen(levels) {
 		return nil
 	}
 	return exp
 }
 
+// parenthesized reports whether the String of exp is already wrapped in parens.
+func parenthesized(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.PrefixExpression, *ast.InfixExpression, *ast.AssignExpression,
+		*ast.IndexExpression, *ast.MemberExpression:
+		return true
+	}
+	return false
+}
+
 
 func (p *Parser) parseIfExpression() ast.Expression{
 	expression := &ast.IfExpression{Token:p.curToken}
@@ -312,19 +761,25 @@ func (p *Parser) parseIfExpression() ast.Expression{
 	}
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN){
+	if expression.Condition == nil || !p.expectPeek(token.RPAREN){
 		return nil
 	}
 	if !p.expectPeek(token.LBRACE){
 		return nil
 	}
 	expression.Consequence = p.parseBlockStatement()
+	if expression.Consequence == nil {
+		return nil
+	}
 	if p.peekTokenIs(token.ELSE){
 		p.nextToken()
 		if !p.expectPeek(token.LBRACE){
 			return nil 
 		}
 		expression.Alternative = p.parseBlockStatement()
+		if expression.Alternative == nil {
+			return nil
+		}
 
 	}
 	return expression
@@ -332,63 +787,477 @@ func (p *Parser) parseIfExpression() ast.Expression{
 }
 
 
+// parseMatchExpression parses match (<subject>) { <arm>, <arm>, ... }. The arms are
+// separated by commas, and a comma after the last one is allowed.
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken, Arms: []*ast.MatchArm{}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+	if exp.Subject == nil || !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	exp.Rbrace = p.curToken
+	return exp
+}
+
+// parseMatchArm parses <pattern> [if <guard>] => <body>
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+		if arm.Guard == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	arm.Token = p.curToken
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	if arm.Body == nil {
+		return nil
+	}
+	return arm
+}
+
+// parsePattern parses the pattern starting at curToken and checks that it binds no
+// name twice
+func (p *Parser) parsePattern() ast.Pattern {
+	pattern := p.parseSubpattern()
+	if pattern == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, name := range ast.Bindings(pattern) {
+		if seen[name.Value] {
+			msg := fmt.Sprintf("duplicate binding %s in pattern", name.Value)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		seen[name.Value] = true
+	}
+	return pattern
+}
+
+// parseSubpattern parses _, a name, a literal such as 1, -1, "s", true or null, or an
+// array or hash pattern
+func (p *Parser) parseSubpattern() ast.Pattern {
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.BindingPattern{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+	case token.INT, token.STRING, token.TRUE, token.FALSE, token.NULL, token.MINUS:
+		value := p.parsePatternLiteral()
+		if value == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: value}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+	msg := fmt.Sprintf("expected pattern, got %s instead", p.curToken.Type)
+	p.errors = append(p.errors, msg)
+	return nil
+}
+
+// parsePatternLiteral parses the literal of a literal pattern or the key of a hash pattern
+func (p *Parser) parsePatternLiteral() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.NULL:
+		return p.parseNullLiteral()
+	case token.MINUS:
+		minus := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		minus.Right = p.parseIntegerLiteral()
+		if minus.Right == nil {
+			return nil
+		}
+		return minus
+	}
+	return nil
+}
+
+// parseArrayPattern parses [<pattern>, ..., ...<rest>]
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken, Elements: []ast.Pattern{}}
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		pattern.Rbracket = p.curToken
+		return pattern
+	}
+	for {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break // the rest comes last
+		}
+		element := p.parseSubpattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.Rbracket = p.curToken
+	return pattern
+}
+
+// parseHashPattern parses {<key>: <pattern>, ...}, where the keys are string, integer
+// or boolean literals, or names standing for the string of the name. A name key on its
+// own binds the value to that name.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken, Pairs: []*ast.HashPatternPair{}}
+	if p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		pattern.Rbrace = p.curToken
+		return pattern
+	}
+	seen := map[string]bool{}
+	for {
+		p.nextToken()
+		pair := &ast.HashPatternPair{}
+		key := ""
+		switch p.curToken.Type {
+		case token.IDENT:
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Key = name
+			key = strconv.Quote(name.Value)
+		case token.STRING, token.INT, token.TRUE, token.FALSE, token.MINUS:
+			pair.Key = p.parsePatternLiteral()
+			if pair.Key == nil {
+				return nil
+			}
+			key = pair.Key.String()
+		default:
+			msg := fmt.Sprintf("expected hash pattern key, got %s instead", p.curToken.Type)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		if seen[key] {
+			msg := fmt.Sprintf("duplicate key %s in pattern", pair.Key)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		seen[key] = true
+		if name, ok := pair.Key.(*ast.Identifier); ok && !p.peekTokenIs(token.COLON) {
+			pair.Value = &ast.BindingPattern{Name: &ast.Identifier{Token: name.Token, Value: name.Value}}
+		} else {
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+			pair.Value = p.parseSubpattern()
+			if pair.Value == nil {
+				return nil
+			}
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.Rbrace = p.curToken
+	return pattern
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement{
+	defer p.leave()
+	if !p.enter() {
+		return nil
+	}
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF){
 		stmt := p.parseStatement()
+		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
+		}
 		p.nextToken()
 	}
+	p.errors = append(p.errors, duplicateFunctions(block.Statements)...)
+	if p.curTokenIs(token.EOF) {
+		if !p.tooDeep {
+			msg := fmt.Sprintf("expected next token to be %s, got %s instead", token.RBRACE, token.EOF)
+			p.errors = append(p.errors, msg)
+		}
+		return nil
+	}
+	block.Rbrace = p.curToken
 	return block
 }
 
 
 func (p *Parser) parseFunctionLiteral() ast.Expression{
 	lit := &ast.FunctionLiteral{Token: p.curToken}
-	if !p.expectPeek(token.LPAREN){
+	if !p.parseFunction(lit) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
-	if !p.expectPeek(token.LBRACE){
+	return lit
+}
+
+// parseFunctionStatement parses fn <name>(<parameters>) { <body> }
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	stmt := &ast.FunctionStatement{Token: p.curToken, Doc: p.docText()}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Name = stmt.Name.Value
+	if !p.parseFunction(lit) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	stmt.Function = lit
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseStructStatement parses struct <name> { <field>, ... }
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken, Doc: p.docText(), Fields: []*ast.Identifier{}}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	declared := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if declared[field.Value] {
+			msg := fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		declared[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+		// a comma is followed by another field
+		if !p.peekTokenIs(token.IDENT) {
+			p.peekError(token.IDENT)
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
 
+// parseMacroLiteral parses macro(<parameters>) { <body> }. The arguments of a macro call
+// are spliced in as syntax, so there is nothing to default and no rest to collect:
+// macro parameters are plain names.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.parseFunction(fn) {
+		return nil
+	}
+	if len(fn.Defaults) > 0 || fn.Rest != nil {
+		p.errors = append(p.errors, "macro parameters cannot have defaults or be variadic")
+		return nil
+	}
+	lit.Parameters = fn.Parameters
+	lit.Body = fn.Body
 	return lit
 }
 
-func(p *Parser) parseFunctionParameters() []*ast.Identifier{
-	identifiers:= []*ast.Identifier{}
-	if p.peekTokenIs(token.RPAREN){
-		p.nextToken()
-		return identifiers
+// duplicateFunctions reports the functions declared more than once among the statements
+// of one program or block, which would make hoisting ambiguous
+func duplicateFunctions(statements []ast.Statement) []string {
+	errors := []string{}
+	declared := map[string]bool{}
+	for _, fs := range ast.HoistedFunctions(statements) {
+		if declared[fs.Name.Value] {
+			errors = append(errors, fmt.Sprintf("function %s is already declared in this scope", fs.Name.Value))
+		}
+		declared[fs.Name.Value] = true
 	}
-	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	return errors
+}
 
-	for p.peekTokenIs(token.COMMA){
+// parseFunction parses the parameters and body of lit, starting on the token before '('
+func (p *Parser) parseFunction(lit *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN){
+		return false
+	}
+	if !p.parseFunctionParameters(lit) || !p.expectPeek(token.LBRACE){
+		return false
+	}
+	// loops do not reach into function bodies, so break and continue start over
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+	return lit.Body != nil
+}
+
+// parseFunctionParameters parses the parameter list of lit up to and including the closing ')'.
+// A parameter is a name, optionally followed by = <default>, and the list may end with a
+// variadic ...name. It returns false once the list turned out to be invalid.
+func(p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool{
+	lit.Parameters = []*ast.Identifier{}
+	if p.peekTokenIs(token.RPAREN){
 		p.nextToken()
+		return true
+	}
+	seen := map[string]bool{}
+	for {
 		p.nextToken()
+		variadic := p.curTokenIs(token.ELLIPSIS)
+		if variadic {
+			p.nextToken()
+		}
+		if !p.curTokenIs(token.IDENT) {
+			msg := fmt.Sprintf("expected parameter name, got %s instead", p.curToken.Type)
+			p.errors = append(p.errors, msg)
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
-	}
-	if !p.expectPeek(token.RPAREN){
-		return nil
+		if seen[ident.Value] {
+			msg := fmt.Sprintf("duplicate parameter %s", ident.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+		seen[ident.Value] = true
+
+		if variadic {
+			if !p.peekTokenIs(token.RPAREN) {
+				msg := fmt.Sprintf("variadic parameter %s must be the last parameter", ident.Value)
+				p.errors = append(p.errors, msg)
+				return false
+			}
+			lit.Rest = ident
+			break
+		}
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			// a default cannot itself be an assignment, so fn(a = b = 1) is rejected
+			value = p.parseExpression(ASSIGNMENT)
+			if value == nil {
+				return false
+			}
+			if lit.Defaults == nil {
+				lit.Defaults = make([]ast.Expression, len(lit.Parameters))
+			}
+		} else if lit.Defaults != nil {
+			msg := fmt.Sprintf("parameter %s without a default follows a parameter with one", ident.Value)
+			p.errors = append(p.errors, msg)
+			return false
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+		if lit.Defaults != nil {
+			lit.Defaults = append(lit.Defaults, value)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
-	return identifiers
+	return p.expectPeek(token.RPAREN)
 }
 
 func(p *Parser) parseCallExpression(function ast.Expression) ast.Expression{ // receives the already parsed function literal and uses it to create a call expression
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.curToken
+	// only a literal callee is known at parse time; other calls are checked when they run
+	if lit, ok := ast.Unparen(function).(*ast.FunctionLiteral); ok {
+		min, max := lit.Arity()
+		if got := len(exp.Arguments); got < min || (max >= 0 && got > max) {
+			msg := fmt.Sprintf("wrong number of arguments: want %s, got %d", arityString(min, max), got)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+	}
 	return exp
 }
 
 
+// arityString describes an argument count range returned by ast.FunctionLiteral.Arity
+func arityString(min, max int) string {
+	switch {
+	case max < 0:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return strconv.Itoa(min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
 func (p *Parser) parseCallArguments() []ast.Expression{
 	args := []ast.Expression{}
 
@@ -397,12 +1266,20 @@ func (p *Parser) parseCallArguments() []ast.Expression{
 		return args
 	}
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	arg := p.parseExpression(LOWEST)
+	if arg == nil {
+		return nil
+	}
+	args = append(args, arg)
 
 	for p.peekTokenIs(token.COMMA){
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		arg := p.parseExpression(LOWEST)
+		if arg == nil {
+			return nil
+		}
+		args = append(args, arg)
 	}
 
 	if !p.expectPeek(token.RPAREN){
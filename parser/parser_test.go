@@ -2,10 +2,17 @@ package parser // Package declaration for the parser package
 
 import (
 	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing" // Importing the testing package
 
 	"github.com/BentleyOph/monke/ast"   // Importing the ast package
 	"github.com/BentleyOph/monke/lexer" // Importing the lexer package
+	"github.com/BentleyOph/monke/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -324,6 +331,16 @@ func TestOperatorPrecedenceParsing(t *testing.T){
 		if actual != tt.expected {
 			t.Errorf("expected = %q, got = %q",tt.expected,actual)
 		}
+		checkGrouping(t, tt.input, program, tt.expected)
+	}
+}
+
+// checkGrouping checks that program is the tree of the fully parenthesized expected,
+// which its String only shows in printed form
+func checkGrouping(t *testing.T, input string, program *ast.Program, expected string) {
+	t.Helper()
+	if !ast.Equal(program, New(lexer.New(expected)).ParseProgram()) {
+		t.Errorf("tree of %q differs from %q", input, expected)
 	}
 }
 
@@ -612,6 +629,244 @@ func TestFunctionParameterParsing(t *testing.T){
 }
 
 
+func TestDefaultAndVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+		expectedString string
+		min, max       int
+	}{
+		{"fn(a, b = 2) {}", []string{"a", "b"}, "", "fn(a, b = 2) { }", 1, 2},
+		{"fn(a = 1 + 2, b = a) {}", []string{"a", "b"}, "", "fn(a = (1 + 2), b = a) { }", 0, 2},
+		{"fn(first, ...rest) { rest }", []string{"first"}, "rest", "fn(first, ...rest) { rest }", 1, -1},
+		{"fn(...args) {}", []string{}, "args", "fn(...args) { }", 0, -1},
+		{"fn(a, b = 1, ...c) {}", []string{"a", "b"}, "c", "fn(a, b = 1, ...c) { }", 1, -1},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got = %d", len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+		if tt.expectedRest == "" && function.Rest != nil {
+			t.Errorf("function.Rest is not nil. got = %s", function.Rest)
+		}
+		if tt.expectedRest != "" && (function.Rest == nil || function.Rest.Value != tt.expectedRest) {
+			t.Errorf("function.Rest wrong. want = %s, got = %v", tt.expectedRest, function.Rest)
+		}
+		if function.String() != tt.expectedString {
+			t.Errorf("function.String() wrong. want = %q, got = %q", tt.expectedString, function.String())
+		}
+		if min, max := function.Arity(); min != tt.min || max != tt.max {
+			t.Errorf("function.Arity() wrong. want = (%d, %d), got = (%d, %d)", tt.min, tt.max, min, max)
+		}
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`fn(1, "x") {}`, "expected parameter name, got INT instead"},
+		{`fn(a, "x") {}`, "expected parameter name, got STRING instead"},
+		{"fn(a,) {}", "expected parameter name, got ) instead"},
+		{"fn(a, a) {}", "duplicate parameter a"},
+		{"fn(a, ...a) {}", "duplicate parameter a"},
+		{"fn(a = 1, b) {}", "parameter b without a default follows a parameter with one"},
+		{"fn(...rest, a) {}", "variadic parameter rest must be the last parameter"},
+		{"fn(...rest = 1) {}", "variadic parameter rest must be the last parameter"},
+		{"fn(... ) {}", "expected parameter name, got ) instead"},
+		{"fn(a = b = 1) {}", "expected next token to be ), got = instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. want first = %q, got = %q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestCallArityOfFunctionLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"fn(a, b) { a }(1, 2)", ""},
+		{"fn(a, b = 2) { a }(1)", ""},
+		{"fn(a, ...rest) { a }(1, 2, 3, 4)", ""},
+		{"f(1)", ""},
+		{"fn(a, b) { a }(1)", "wrong number of arguments: want 2, got 1"},
+		{"fn() { 1 }(1)", "wrong number of arguments: want 0, got 1"},
+		{"fn(a, b = 2) { a }(1, 2, 3)", "wrong number of arguments: want 1 to 2, got 3"},
+		{"fn(a, ...rest) { a }()", "wrong number of arguments: want at least 1, got 0"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if tt.expectedError == "" {
+			checkParserErrors(t, p)
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("wrong errors for %q. want = [%q], got = %q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestFunctionStatements(t *testing.T) {
+	input := `
+fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } };
+isEven(10);
+`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got = %d", len(program.Statements))
+	}
+
+	for i, name := range []string{"isEven", "isOdd"} {
+		stmt, ok := program.Statements[i].(*ast.FunctionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not *ast.FunctionStatement. got = %T", i, program.Statements[i])
+		}
+		if !testIdentifier(t, stmt.Name, name) {
+			return
+		}
+		if stmt.Function.Name != name {
+			t.Errorf("stmt.Function.Name wrong. want = %s, got = %s", name, stmt.Function.Name)
+		}
+		if len(stmt.Function.Parameters) != 1 {
+			t.Errorf("stmt.Function.Parameters wrong. got = %d", len(stmt.Function.Parameters))
+		}
+	}
+
+	hoisted := ast.HoistedFunctions(program.Statements)
+	if len(hoisted) != 2 || hoisted[0].Name.Value != "isEven" || hoisted[1].Name.Value != "isOdd" {
+		t.Errorf("ast.HoistedFunctions wrong. got = %v", hoisted)
+	}
+
+	expected := "fn isEven(n) { if ((n == 0)) { true } else { isOdd((n - 1)) } }"
+	if actual := program.Statements[0].String(); actual != expected {
+		t.Errorf("String() wrong. want = %q, got = %q", expected, actual)
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+	}{
+		{"let add = fn(a, b) { a + b };", "add"},
+		{"fn add(a, b) { a + b }", "add"},
+		{"fn(a, b) { a + b }", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var function *ast.FunctionLiteral
+		switch stmt := program.Statements[0].(type) {
+		case *ast.LetStatement:
+			function = stmt.Value.(*ast.FunctionLiteral)
+		case *ast.FunctionStatement:
+			function = stmt.Function
+		case *ast.ExpressionStatement:
+			function = stmt.Expression.(*ast.FunctionLiteral)
+		}
+		if function.Name != tt.expectedName {
+			t.Errorf("function.Name wrong for %q. want = %q, got = %q", tt.input, tt.expectedName, function.Name)
+		}
+	}
+}
+
+func TestDuplicateFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{"fn f() { 1 } fn g() { 2 }", []string{}},
+		{"fn f() { 1 } let g = fn() { fn f() { 2 } };", []string{}},
+		{"fn f() { 1 } fn f() { 2 }", []string{"function f is already declared in this scope"}},
+		{"if (x) { fn f() { 1 } fn f() { 2 } }", []string{"function f is already declared in this scope"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong errors for %q. want = %q, got = %q", tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, msg, errors[i])
+			}
+		}
+	}
+}
+
+
+func TestDocComments(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedDoc string
+	}{
+		{"/// Adds two numbers.\n///\n///   Indented.  \nfn add(a, b) { a + b }", "Adds two numbers.\n\n  Indented."},
+		{"///Pi.\r\nlet pi = 3;", "Pi."},
+		{"let x = 1;", ""},
+		{"// plain\n//// separator\nlet x = 1;", ""},
+		{"/// lost\nx; let y = 2;", ""},
+		{"let f = /// inside\n fn() {};", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var doc string
+		switch stmt := program.Statements[len(program.Statements)-1].(type) {
+		case *ast.LetStatement:
+			doc = stmt.Doc
+		case *ast.FunctionStatement:
+			doc = stmt.Doc
+		default:
+			t.Fatalf("last statement of %q is not a declaration. got = %T", tt.input, stmt)
+		}
+		if doc != tt.expectedDoc {
+			t.Errorf("doc of %q wrong. want = %q, got = %q", tt.input, tt.expectedDoc, doc)
+		}
+	}
+
+	// declarations in blocks are documented too
+	p := New(lexer.New("fn f() {\n\t/// Local.\n\tlet a = 1;\n\ta\n}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	let := program.Statements[0].(*ast.FunctionStatement).Function.Body.Statements[0].(*ast.LetStatement)
+	if let.Doc != "Local." {
+		t.Errorf("doc of a local let wrong. got = %q", let.Doc)
+	}
+}
 
 func TestCallExpressionParsing(t *testing.T){
 	input := "add(1,2*3,4+5);"
@@ -716,4 +971,1208 @@ func TestStringLiteralExpressikon(t *testing.T){
 		t.Errorf("literal.Value not %q. got = %q","hello world",literal.Value)
 	}
 	
-}
\ No newline at end of file
+}
+
+func TestParserExtensions(t *testing.T) {
+	const (
+		IN    token.TokenType = "IN"
+		ARROW token.TokenType = "=>"
+	)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x in xs", "(x in xs)"},
+		{"a + b in xs", "((a + b) in xs)"},
+		{"x in a == b", "((x in a) == b)"},
+		{"a => b => c", "(a => (b => c))"},
+		{"x => y in ys", "(x => (y in ys))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		l.RegisterKeyword("in", IN)
+		l.RegisterOperator("=>", ARROW)
+		p := New(l)
+		p.RegisterInfix(IN, p.ParseInfixExpression)
+		p.SetPrecedence(IN, LESSGREATER, LeftAssoc)
+		p.RegisterInfix(ARROW, p.ParseInfixExpression)
+		p.SetPrecedence(ARROW, EQUALS, RightAssoc)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParserExtensionsArePerParser(t *testing.T) {
+	p := New(lexer.New("x"))
+	p.SetPrecedence(token.PLUS, PRODUCT, LeftAssoc)
+
+	l := lexer.New("a * b + c")
+	program := New(l).ParseProgram()
+	if actual := program.String(); actual != "((a * b) + c)" {
+		t.Errorf("precedence leaked between parsers. got = %q", actual)
+	}
+}
+
+func TestInfixStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"infix 5 left <+> = fn(a, b) { a }; x <+> y <+> z",
+			"infix 5 left <+> = fn(a, b) { a }; ((x <+> y) <+> z)",
+		},
+		{
+			"infix 5 right <+> = add; x <+> y <+> z",
+			"infix 5 right <+> = add; (x <+> (y <+> z))",
+		},
+		{
+			"infix 2 left |> = pipe; a + b |> f * g",
+			"infix 2 left |> = pipe; ((a + b) |> (f * g))",
+		},
+		{
+			"infix 6 left $ = apply; a * b $ c",
+			"infix 6 left $ = apply; (a * (b $ c))",
+		},
+		{
+			"infix 4 left <+> = add; a < b <+> c",
+			"infix 4 left <+> = add; (a < (b <+> c))",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("program.Statements does not contain 2 statements. got = %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.InfixStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.InfixStatement. got = %T", program.Statements[0])
+		}
+		if stmt.Function == nil {
+			t.Fatalf("stmt.Function is nil")
+		}
+
+		actual := stmt.String() + " " + program.Statements[1].String()
+		if actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestInfixStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"infix 9 left <+> = add;", "infix precedence must be between 2 and 6, got 9"},
+		{"infix 5 up <+> = add;", "infix associativity must be left or right, got up"},
+		{"infix 5 left foo = add;", "expected operator symbol, got foo"},
+		{"infix 5 left + = add;", "operator + is already defined"},
+		{"infix 5 left => = add;", "operator => is already defined"},
+		{"infix 4 left ? = f;", "operator ? would hide the built-in operator ??"},
+		{"infix 4 left .. = f;", "operator .. would hide the built-in operator ..."},
+		{"infix 4 left * = f;", "operator * is already defined"},
+		{"infix 4 left ! = f;", "operator ! is already defined"},
+		{"infix 5 left <+> = add; infix 4 left <+> = add;", "operator <+> is already defined"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+// TestRejectedOperatorKeepsBuiltins checks that a declaration rejected for hiding a
+// built-in operator leaves that operator working for the rest of the input
+func TestRejectedOperatorKeepsBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // the statements after the declaration
+	}{
+		{"infix 4 left ? = f;\nx?.y; c ?? d; a?[0]", []string{"(x?.y)", "(c ?? d)", "(a?[0])"}},
+		{"infix 4 left .. = f;\nfn(...r) { r };\nlet [a, ...rest] = b;", []string{"fn(...r) { r }", "let [a, ...rest] = b;"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(program.Statements) < len(tt.expected) {
+			t.Fatalf("%q: expected at least %d statements. got = %d", tt.input, len(tt.expected), len(program.Statements))
+		}
+		rest := program.Statements[len(program.Statements)-len(tt.expected):]
+		for i, s := range rest {
+			if s.String() != tt.expected[i] {
+				t.Errorf("%q: wrong statement %d. want = %q, got = %q", tt.input, i, tt.expected[i], s.String())
+			}
+		}
+	}
+}
+
+func TestOperatorAssociativity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// left-associative
+		{"a - b - c", "((a - b) - c)"},
+		{"a / b / c", "((a / b) / c)"},
+		{"a == b == c", "((a == b) == c)"},
+		// right-associative
+		{"a ** b", "(a ** b)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a ** b ** c ** d", "(a ** (b ** (c ** d)))"},
+		{"a * b ** c * d", "((a * (b ** c)) * d)"},
+		{"a ** b * c", "((a ** b) * c)"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"f(a) ** b ** g(c)", "(f(a) ** (b ** g(c)))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		checkGrouping(t, tt.input, program, tt.expected)
+	}
+}
+
+func TestPowerExpression(t *testing.T) {
+	l := lexer.New("2 ** 3 ** 2")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.InfixExpression. got = %T", stmt.Expression)
+	}
+	if !testLiteralExpression(t, exp.Left, 2) {
+		return
+	}
+	if exp.Operator != "**" {
+		t.Fatalf("exp.Operator is not '**'. got = %s", exp.Operator)
+	}
+	testInfixExpression(t, exp.Right, 3, "**", 2)
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := "while (x < y) { x; break; }"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got = %d", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got = %T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got = %d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("Statements[1] is not ast.BreakStatement. got = %T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedInit string
+		expectedCond string
+		expectedPost string
+	}{
+		{"for (let i = 0; i < n; i = i + 1) { continue; }", "let i = 0;", "(i < n)", "(i = (i + 1))"},
+		{"for (let i = 0; i < n; next(i)) { continue; }", "let i = 0;", "(i < n)", "next(i)"},
+		{"for (i; i < n; i) { continue; }", "i", "(i < n)", "i"},
+		{"for (; i < n;) { continue; }", "", "(i < n)", ""},
+		{"for (;;) { continue; }", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got = %d", 1, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got = %T", program.Statements[0])
+		}
+		if actual := nodeString(stmt.Init); actual != tt.expectedInit {
+			t.Errorf("stmt.Init wrong. want = %q, got = %q", tt.expectedInit, actual)
+		}
+		if actual := nodeString(stmt.Condition); actual != tt.expectedCond {
+			t.Errorf("stmt.Condition wrong. want = %q, got = %q", tt.expectedCond, actual)
+		}
+		if actual := nodeString(stmt.Post); actual != tt.expectedPost {
+			t.Errorf("stmt.Post wrong. want = %q, got = %q", tt.expectedPost, actual)
+		}
+		if len(stmt.Body.Statements) != 1 {
+			t.Fatalf("body is not 1 statement. got = %d", len(stmt.Body.Statements))
+		}
+		if _, ok := stmt.Body.Statements[0].(*ast.ContinueStatement); !ok {
+			t.Fatalf("Statements[0] is not ast.ContinueStatement. got = %T", stmt.Body.Statements[0])
+		}
+	}
+}
+
+// nodeString returns the String() of node, or "" when node is a nil interface
+func nodeString(node ast.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.String()
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "break outside of loop"},
+		{"continue;", "continue outside of loop"},
+		{"if (x) { break; }", "break outside of loop"},
+		{"while (x) { let f = fn() { continue; }; }", "continue outside of loop"},
+		{"for (let i = 0 i < n; i) { }", "expected next token to be ;, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+
+	valid := []string{
+		"while (x) { if (y) { break; } continue; }",
+		"for (;;) { while (y) { break; } break; }",
+		"while (x) { let f = fn() { while (y) { break; } }; break; }",
+	}
+	for _, input := range valid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x += 5", "(x += 5)"},
+		{"x -= 5", "(x -= 5)"},
+		{"x *= 5", "(x *= 5)"},
+		{"x /= 5", "(x /= 5)"},
+		{"x = y = 5", "(x = (y = 5))"},
+		{"x += y * 2 == z", "(x += ((y * 2) == z))"},
+		{"arr[i] = v", "((arr[i]) = v)"},
+		{`h["k"] = v`, "((h[\"k\"]) = v)"},
+		{"m[i][j] += 1", "(((m[i])[j]) += 1)"},
+		{"let y = x = 3;", "let y = (x = 3);"},
+		{"a * b[2]", "(a * (b[2]))"},
+		{"add(a)[b + 1]", "(add(a)[(b + 1)])"},
+		{"p.x = 3", "((p.x) = 3)"},
+		{"p.x += q.y", "((p.x) += (q.y))"},
+		{"(a?.b).c = 1", "(((a?.b).c) = 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+	}
+}
+
+func TestAssignExpressionNodes(t *testing.T) {
+	l := lexer.New("total += count;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.AssignExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AssignExpression. got = %T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Target, "total") {
+		return
+	}
+	if exp.Operator != "+=" {
+		t.Fatalf("exp.Operator is not '+='. got = %s", exp.Operator)
+	}
+	testIdentifier(t, exp.Value, "count")
+}
+
+func TestInvalidAssignmentTargets(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"5 = x", "invalid assignment target 5"},
+		{"a + b = c", "invalid assignment target (a + b)"},
+		{"f() += 1", "invalid assignment target f()"},
+		{"a?[0] = 1", "invalid assignment target (a?[0])"},
+		{"a?.b = 1", "invalid assignment target (a?.b)"},
+		{"a.b() = 1", "invalid assignment target (a.b)()"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := "macro(x, y) { x + y; }"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got = %d", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got = %T", program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got = %T", stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got = %d", len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement. got = %d", len(macro.Body.Statements))
+	}
+	body, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got = %T", macro.Body.Statements[0])
+	}
+	testInfixExpression(t, body.Expression, "x", "+", "y")
+}
+
+func TestMacroLiteralErrors(t *testing.T) {
+	tests := []string{
+		"macro(a, b = 1) { a }",
+		"macro(...rest) { rest }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", input)
+		}
+		if errors[0] != "macro parameters cannot have defaults or be variadic" {
+			t.Errorf("wrong error for %q. got = %q", input, errors[0])
+		}
+		if len(program.Statements) != 0 {
+			t.Errorf("expected no statements for %q. got = %s", input, program.String())
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	p := New(lexer.New("null;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got = %d", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got = %T", program.Statements[0])
+	}
+	if _, ok := stmt.Expression.(*ast.NullLiteral); !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got = %T", stmt.Expression)
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`cfg?.db?.host ?? "localhost"`, `(((cfg?.db)?.host) ?? "localhost")`},
+		{"a?[0]?[1]", "((a?[0])?[1])"},
+		{"a?.b[0]?.c", "(((a?.b)[0])?.c)"},
+		{"f(x)?.y(1)", "(f(x)?.y)(1)"},
+		{"-a?.b", "(-(a?.b))"},
+		{"a ?? b ?? null", "((a ?? b) ?? null)"},
+		{"a ?? b + 1", "(a ?? (b + 1))"},
+		{"a ?? 0 < 5", "((a ?? 0) < 5)"},
+		{"a == b ?? c", "(a == (b ?? c))"},
+		{"x = a?.b ?? 1", "(x = ((a?.b) ?? 1))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		checkGrouping(t, tt.input, program, tt.expected)
+	}
+}
+
+func TestOptionalChainingNodes(t *testing.T) {
+	p := New(lexer.New("a?.b; a?[0]; a[0]"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	member, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got = %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if !member.Optional || !testIdentifier(t, member.Object, "a") || !testIdentifier(t, member.Property, "b") {
+		t.Errorf("wrong member expression %s", member)
+	}
+	if member.Pos() != 0 || member.End() != len("a?.b") {
+		t.Errorf("member expression covers [%d, %d), want [0, 4)", member.Pos(), member.End())
+	}
+	for i, optional := range []bool{true, false} {
+		stmt := program.Statements[i+1].(*ast.ExpressionStatement)
+		index, ok := stmt.Expression.(*ast.IndexExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.IndexExpression. got = %T", stmt.Expression)
+		}
+		if index.Optional != optional {
+			t.Errorf("%s has Optional = %t, want %t", index, index.Optional, optional)
+		}
+	}
+
+	p = New(lexer.New("a?.1"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected next token to be IDENT, got INT instead" {
+		t.Errorf("wrong errors for a?.1: %v", errors)
+	}
+}
+
+func TestFieldAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"p.x", "(p.x)"},
+		{"p.x.y", "((p.x).y)"},
+		{"p.move(1)", "(p.move)(1)"},
+		{"f(x).y", "(f(x).y)"},
+		{"a[0].b[1]", "(((a[0]).b)[1])"},
+		{"a?.b.c", "((a?.b).c)"},
+		{"-p.x", "(-(p.x))"},
+		{"p.x + q.y * 2", "((p.x) + ((q.y) * 2))"},
+		{"p.x ?? 0", "((p.x) ?? 0)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		checkGrouping(t, tt.input, program, tt.expected)
+	}
+
+	p := New(lexer.New("p.x"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	member := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MemberExpression)
+	if member.Optional || member.Token.Type != token.DOT {
+		t.Errorf("p.x parsed as optional access %s with token %q", member, member.Token.Type)
+	}
+
+	p = New(lexer.New("p.1"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "expected next token to be IDENT, got INT instead" {
+		t.Errorf("wrong errors for p.1: %v", errors)
+	}
+}
+
+func TestStructStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       string
+		expectedFields []string
+	}{
+		{"struct Point { x, y }", "struct Point { x, y }", []string{"x", "y"}},
+		{"struct Point { x, y };", "struct Point { x, y }", []string{"x", "y"}},
+		{"struct Unit {}", "struct Unit { }", []string{}},
+		{"struct User {\n\tname,\n\tage\n}", "struct User { name, age }", []string{"name", "age"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got = %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.StructStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.StructStatement. got = %T", program.Statements[0])
+		}
+		if len(stmt.Fields) != len(tt.expectedFields) {
+			t.Fatalf("wrong number of fields for %q. want = %d, got = %d", tt.input, len(tt.expectedFields), len(stmt.Fields))
+		}
+		for i, field := range tt.expectedFields {
+			testIdentifier(t, stmt.Fields[i], field)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		if text := tt.input[stmt.Pos():stmt.End()]; text != strings.TrimSuffix(tt.input, ";") {
+			t.Errorf("wrong range of %q. got = %q", tt.input, text)
+		}
+		checkRoundTrip(t, tt.input, program)
+	}
+
+	p := New(lexer.New("/// A point.\nstruct Point { x, y }\nlet p = Point(1, 2); p.x = p.y;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if stmt := program.Statements[0].(*ast.StructStatement); stmt.Doc != "A point." {
+		t.Errorf("wrong doc. got = %q", stmt.Doc)
+	}
+	if len(program.Statements) != 3 {
+		t.Errorf("wrong number of statements. want = 3, got = %d: %s", len(program.Statements), program)
+	}
+}
+
+func TestStructStatementErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"struct Point { x, x }", "duplicate field x in struct Point"},
+		{"struct Point { x, y, }", "expected next token to be IDENT, got } instead"},
+		{"struct Point { x y }", "expected next token to be }, got IDENT instead"},
+		{"struct Point { 1 }", "expected next token to be IDENT, got INT instead"},
+		{"struct { x }", "expected next token to be IDENT, got { instead"},
+		{"struct Point x", "expected next token to be {, got IDENT instead"},
+		{"struct Point { x", "expected next token to be }, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+		for _, s := range program.Statements {
+			if _, ok := s.(*ast.StructStatement); ok {
+				t.Errorf("partial struct statement for %q: %s", tt.input, s)
+			}
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) { 0 => "zero", [first, ...rest] if first > 0 => first, {"name": n} => n, _ => null, }`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got = %d", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got = %T", program.Statements[0])
+	}
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got = %T", stmt.Expression)
+	}
+	testIdentifier(t, match.Subject, "x")
+	if len(match.Arms) != 4 {
+		t.Fatalf("match.Arms does not contain 4 arms. got = %d", len(match.Arms))
+	}
+
+	literal, ok := match.Arms[0].Pattern.(*ast.LiteralPattern)
+	if !ok {
+		t.Fatalf("Arms[0].Pattern is not ast.LiteralPattern. got = %T", match.Arms[0].Pattern)
+	}
+	testIntegerLiteral(t, literal.Value, 0)
+	if match.Arms[0].Guard != nil {
+		t.Errorf("Arms[0] has a guard %s", match.Arms[0].Guard)
+	}
+
+	array, ok := match.Arms[1].Pattern.(*ast.ArrayPattern)
+	if !ok {
+		t.Fatalf("Arms[1].Pattern is not ast.ArrayPattern. got = %T", match.Arms[1].Pattern)
+	}
+	if len(array.Elements) != 1 || array.Rest == nil || array.Rest.Value != "rest" {
+		t.Fatalf("wrong array pattern %s", array)
+	}
+	binding, ok := array.Elements[0].(*ast.BindingPattern)
+	if !ok || binding.Name.Value != "first" {
+		t.Errorf("array.Elements[0] is not the binding first. got = %s", array.Elements[0])
+	}
+	testInfixExpression(t, match.Arms[1].Guard, "first", ">", 0)
+	testIdentifier(t, match.Arms[1].Body, "first")
+
+	hash, ok := match.Arms[2].Pattern.(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("Arms[2].Pattern is not ast.HashPattern. got = %T", match.Arms[2].Pattern)
+	}
+	if len(hash.Pairs) != 1 || hash.Pairs[0].Key.String() != `"name"` || hash.Pairs[0].Value.String() != "n" {
+		t.Errorf("wrong hash pattern %s", hash)
+	}
+
+	if _, ok := match.Arms[3].Pattern.(*ast.WildcardPattern); !ok {
+		t.Errorf("Arms[3].Pattern is not ast.WildcardPattern. got = %T", match.Arms[3].Pattern)
+	}
+	if _, ok := match.Arms[3].Body.(*ast.NullLiteral); !ok {
+		t.Errorf("Arms[3].Body is not ast.NullLiteral. got = %T", match.Arms[3].Body)
+	}
+
+	ranges := []struct {
+		node ast.Node
+		text string
+	}{
+		{match, input},
+		{match.Arms[1], "[first, ...rest] if first > 0 => first"},
+		{array, "[first, ...rest]"},
+		{hash, `{"name": n}`},
+		{hash.Pairs[0].Value, "n"},
+	}
+	for _, r := range ranges {
+		if text := input[r.node.Pos():r.node.End()]; text != r.text {
+			t.Errorf("%T covers the wrong text. want = %q, got = %q", r.node, r.text, text)
+		}
+	}
+}
+
+func TestMatchExpressionStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) {}", "match (x) { }"},
+		{"match (x) { _ => 1 }", "match (x) { _ => 1 }"},
+		{"match (a + b) { -1 => true, 1 => false, }", "match ((a + b)) { -1 => true, 1 => false }"},
+		{`match (x) { [] => 0, [a] => a, [a, b, ..._] => a + b }`, "match (x) { [] => 0, [a] => a, [a, b, ..._] => (a + b) }"},
+		{`match (x) { {"a": [x, {"b": -1}], 1: _, true: null} => x }`, `match (x) { {"a": [x, {"b": -1}], 1: _, true: null} => x }`},
+		{"match (x) { n if n > 0 => n, n if (f(n)) => -n }", "match (x) { n if (n > 0) => n, n if f(n) => (-n) }"},
+		{"1 + match (x) { _ => 2 } * 3", "(1 + (match (x) { _ => 2 } * 3))"},
+		{"let f = fn(x) { match (x) { 0 => 1, n => n * f(n - 1) } };", "let f = fn(x) { match (x) { 0 => 1, n => (n * f((n - 1))) } };"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		checkRoundTrip(t, tt.input, program)
+	}
+}
+
+func TestPatternErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"match (x) { [a, a] => 1 }", "duplicate binding a in pattern"},
+		{"match (x) { {\"a\": n, \"b\": [n]} => 1 }", "duplicate binding n in pattern"},
+		{"match (x) { [...r, ...r] => 1 }", "expected next token to be ], got , instead"},
+		{"match (x) { [...rest, a] => 1 }", "expected next token to be ], got , instead"},
+		{"match (x) { {\"a\": 1, \"a\": 2} => 1 }", "duplicate key \"a\" in pattern"},
+		{"match (x) { {[a]: 1} => 1 }", "expected hash pattern key, got [ instead"},
+		{"match (x) { {a, \"a\": 1} => 1 }", "duplicate key \"a\" in pattern"},
+		{"match (x) { {a, b: a} => 1 }", "duplicate binding a in pattern"},
+		{"match (x) { {\"a\" 1} => 1 }", "expected next token to be :, got INT instead"},
+		{"match (x) { f(y) => 1 }", "expected next token to be =>, got ( instead"},
+		{"match (x) { (y) => 1 }", "expected pattern, got ( instead"},
+		{"match (x) { -y => 1 }", "expected next token to be INT, got IDENT instead"},
+		{"match (x) { 1 => 2 3 => 4 }", "expected next token to be }, got INT instead"},
+		{"match (x) { 1 => }", "no prefix parse function for } found"},
+		{"match x { _ => 1 }", "expected next token to be (, got IDENT instead"},
+		{"match (x) { _ => 1", "expected next token to be }, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+		for _, s := range program.Statements {
+			if _, ok := s.(*ast.ExpressionStatement); ok && strings.HasPrefix(s.String(), "match") {
+				t.Errorf("partial match expression for %q: %s", tt.input, s)
+			}
+		}
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      string
+		expectedNames []string
+	}{
+		{"let [a, b, ...rest] = xs;", "let [a, b, ...rest] = xs;", []string{"a", "b", "rest"}},
+		{"let [] = xs;", "let [] = xs;", []string{}},
+		{"let [_, [x, ..._]] = xs;", "let [_, [x, ..._]] = xs;", []string{"x"}},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;", []string{"name", "years"}},
+		{"let {name: name} = person;", "let {name} = person;", []string{"name"}},
+		{`let {"id": id, 1: [first], tags} = f(x);`, `let {"id": id, 1: [first], tags} = f(x);`, []string{"id", "first", "tags"}},
+		{"let [f, 0] = fn(x) { x };", "let [f, 0] = fn(x) { x };", []string{"f"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got = %d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatement. got = %T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Errorf("expected a pattern and no name for %q. got name = %v, pattern = %v", tt.input, stmt.Name, stmt.Pattern)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected = %q, got = %q", tt.expected, actual)
+		}
+		names := []string{}
+		for _, name := range stmt.Names() {
+			names = append(names, name.Value)
+		}
+		if strings.Join(names, " ") != strings.Join(tt.expectedNames, " ") {
+			t.Errorf("wrong names for %q. want = %v, got = %v", tt.input, tt.expectedNames, names)
+		}
+		if text := tt.input[stmt.Pos():stmt.End()]; text != strings.TrimSuffix(tt.input, ";") {
+			t.Errorf("wrong range of %q. got = %q", tt.input, text)
+		}
+		if text := tt.input[stmt.Pattern.Pos():stmt.Pattern.End()]; !strings.HasPrefix(tt.input, "let "+text+" = ") {
+			t.Errorf("wrong pattern range of %q. got = %q", tt.input, text)
+		}
+		if lit, ok := ast.Unparen(stmt.Value).(*ast.FunctionLiteral); ok && lit.Name != "" {
+			t.Errorf("function literal destructured by %q is named %q", tt.input, lit.Name)
+		}
+		checkRoundTrip(t, tt.input, program)
+	}
+}
+
+func TestDestructuringLetErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let [a, a] = xs;", "duplicate binding a in pattern"},
+		{"let {a, b: [a]} = x;", "duplicate binding a in pattern"},
+		{"let [a, f(b)] = xs;", "expected next token to be ], got ( instead"},
+		{"let {a} x;", "expected next token to be =, got IDENT instead"},
+		{"let [a, ...r, b] = xs;", "expected next token to be ], got , instead"},
+		{"let (a) = x;", "expected next token to be IDENT, got ( instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q", tt.input)
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. want = %q, got = %q", tt.input, tt.expectedError, errors[0])
+		}
+		for _, s := range program.Statements {
+			if _, ok := s.(*ast.LetStatement); ok {
+				t.Errorf("partial let statement for %q: %s", tt.input, s)
+			}
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let r = add(1, 2 * x);\nif (r > 1) { r } else { -r }\nfn f(a, b = \"s\") { for (;;) { a[i] += 1; break; } }\n(1 + 2) * 3"
+	expected := []struct {
+		nodeType string
+		text     string
+	}{
+		{"*ast.Program", input},
+		{"*ast.LetStatement", "let r = add(1, 2 * x)"},
+		{"*ast.Identifier", "r"},
+		{"*ast.CallExpression", "add(1, 2 * x)"},
+		{"*ast.Identifier", "add"},
+		{"*ast.IntegerLiteral", "1"},
+		{"*ast.InfixExpression", "2 * x"},
+		{"*ast.IntegerLiteral", "2"},
+		{"*ast.Identifier", "x"},
+		{"*ast.ExpressionStatement", "if (r > 1) { r } else { -r }"},
+		{"*ast.IfExpression", "if (r > 1) { r } else { -r }"},
+		{"*ast.InfixExpression", "r > 1"},
+		{"*ast.Identifier", "r"},
+		{"*ast.IntegerLiteral", "1"},
+		{"*ast.BlockStatement", "{ r }"},
+		{"*ast.ExpressionStatement", "r"},
+		{"*ast.Identifier", "r"},
+		{"*ast.BlockStatement", "{ -r }"},
+		{"*ast.ExpressionStatement", "-r"},
+		{"*ast.PrefixExpression", "-r"},
+		{"*ast.Identifier", "r"},
+		{"*ast.FunctionStatement", `fn f(a, b = "s") { for (;;) { a[i] += 1; break; } }`},
+		{"*ast.Identifier", "f"},
+		{"*ast.FunctionLiteral", `fn f(a, b = "s") { for (;;) { a[i] += 1; break; } }`},
+		{"*ast.Identifier", "a"},
+		{"*ast.Identifier", "b"},
+		{"*ast.StringLiteral", `"s"`},
+		{"*ast.BlockStatement", "{ for (;;) { a[i] += 1; break; } }"},
+		{"*ast.ForStatement", "for (;;) { a[i] += 1; break; }"},
+		{"*ast.BlockStatement", "{ a[i] += 1; break; }"},
+		{"*ast.ExpressionStatement", "a[i] += 1"},
+		{"*ast.AssignExpression", "a[i] += 1"},
+		{"*ast.IndexExpression", "a[i]"},
+		{"*ast.Identifier", "a"},
+		{"*ast.Identifier", "i"},
+		{"*ast.IntegerLiteral", "1"},
+		{"*ast.BreakStatement", "break"},
+		{"*ast.ExpressionStatement", "(1 + 2) * 3"},
+		{"*ast.InfixExpression", "(1 + 2) * 3"},
+		{"*ast.ParenExpression", "(1 + 2)"},
+		{"*ast.InfixExpression", "1 + 2"},
+		{"*ast.IntegerLiteral", "1"},
+		{"*ast.IntegerLiteral", "2"},
+		{"*ast.IntegerLiteral", "3"},
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	var nodes []ast.Node
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	if len(nodes) != len(expected) {
+		t.Fatalf("wrong number of nodes. want = %d, got = %d", len(expected), len(nodes))
+	}
+	for i, tt := range expected {
+		node := nodes[i]
+		if nodeType := fmt.Sprintf("%T", node); nodeType != tt.nodeType {
+			t.Fatalf("nodes[%d] has the wrong type. want = %s, got = %s", i, tt.nodeType, nodeType)
+		}
+		if text := input[node.Pos():node.End()]; text != tt.text {
+			t.Errorf("nodes[%d] (%s) covers the wrong text. want = %q, got = %q", i, tt.nodeType, tt.text, text)
+		}
+	}
+}
+
+// malformedInputs are inputs that used to leave nil children in the AST
+var malformedInputs = []string{
+	"-",
+	"!",
+	"1 +",
+	"(1 + 2",
+	"if",
+	"if (",
+	"if (x",
+	"if (x) {",
+	"if (x) { y } else",
+	"if (x) { y } else {",
+	"fn",
+	"fn(",
+	"fn(x, ",
+	"fn(x) {",
+	"add(1, ",
+	"add(1, 2",
+	"let",
+	"let x",
+	"let x =",
+	"let x = ;",
+	"return",
+	"return ;",
+	"x[",
+	"x[1",
+	"x = ",
+	"while (x) {",
+	"for (;;",
+	"for (let i = ; i; i) {}",
+	"infix 5 left <+> =",
+	"match (x) { [a, ",
+	"match (x) { {\"a\": ",
+	"match (x) { 99999999999999999999999 => 1 }",
+	"match (x) { n if => 1 }",
+	"struct P { x, ",
+	"struct P { x, x }",
+	"p.",
+	"99999999999999999999999",
+	"} ) ] ; , = == != + - * / < > ** += \x00",
+}
+
+func TestMalformedInputIsRejectedCompletely(t *testing.T) {
+	for _, input := range malformedInputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+		_ = program.String()
+		if msg := findNilChild(program, "program"); msg != "" {
+			t.Errorf("half-built node in %q: %s", input, msg)
+		}
+	}
+}
+
+func TestNestingDepthLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxDepth int
+	}{
+		{strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000), DefaultMaxDepth},
+		{strings.Repeat("-", 100000) + "1", DefaultMaxDepth},
+		{strings.Repeat("2 ** ", 100000) + "2", DefaultMaxDepth},
+		{strings.Repeat("while (true) { ", 100000), DefaultMaxDepth},
+		{strings.Repeat("fn() { ", 100000), DefaultMaxDepth},
+		{strings.Repeat("f(", 100000), DefaultMaxDepth},
+		{"let x = " + strings.Repeat("(", 11) + "1" + strings.Repeat(")", 11) + "; x", 10},
+		{"if (x) { if (x) { if (x) { 1 } } }", 5},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.SetMaxDepth(tt.maxDepth)
+		program := p.ParseProgram()
+		expected := fmt.Sprintf("maximum nesting depth of %d exceeded", tt.maxDepth)
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != expected {
+			t.Errorf("wrong errors for %.40q. want = [%q], got = %q", tt.input, expected, errors)
+		}
+		if len(program.Statements) != 0 {
+			t.Errorf("expected no statements for %.40q. got = %d", tt.input, len(program.Statements))
+		}
+	}
+}
+
+func TestNestingBelowDepthLimit(t *testing.T) {
+	input := strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100) + "; x"
+	p := New(lexer.New(input))
+	p.SetMaxDepth(101)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got = %d", len(program.Statements))
+	}
+}
+
+func FuzzParseProgram(f *testing.F) {
+	for _, input := range malformedInputs {
+		f.Add(input)
+	}
+	f.Add("let add = fn(x, y) { x + y; }; add(1, 2 * 3)[0];")
+	f.Add(strings.Repeat("-", 600) + "1")
+	f.Add(strings.Repeat("1 + ", 1200) + "1")
+	f.Add(strings.Repeat("a[", 600) + "1" + strings.Repeat("]", 600))
+	f.Add("if (x < y) { x } else { y }")
+	f.Add("for (let i = 0; i < 10; i += 1) { if (i == 5) { break; } }")
+	f.Add("infix 6 right <+> = fn(a, b) { a }; 1 <+> 2 <+> 3")
+	f.Add(`"unterminated`)
+	f.Add("fn(a, b = 2, ...rest) { a }(1)")
+	f.Add("fn even(n) { odd(n - 1) } fn odd(n) { even(n - 1) }")
+	f.Add("/// Doc.\nlet x = 1; /// stray\nfn f() { /// inner\n }")
+	f.Add("cfg?.db?[0] ?? null; let m = macro(a) { quote(unquote(a)) };")
+	f.Add("let [a, ...r] = xs; let {name, age: [y]} = p;")
+	f.Add(`match (x) { 0 => null, [a, ...r] if a > 0 => r, {"k": -1} => 1, _ => 2, }`)
+	f.Add("struct Point { x, y } let p = Point(1, 2); p.x = p.y + p?.z;")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			checkRoundTrip(t, input, program)
+		}
+		if msg := findNilChild(program, "program"); msg != "" {
+			t.Fatalf("half-built node in %q: %s", input, msg)
+		}
+	})
+}
+
+// TestStringRoundTrip parses String() of every input in this file that parses
+// without errors and checks that it yields the same tree
+func TestStringRoundTrip(t *testing.T) {
+	file, err := goparser.ParseFile(gotoken.NewFileSet(), "parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatalf("cannot read the test inputs: %v", err)
+	}
+	checked := 0
+	goast.Inspect(file, func(node goast.Node) bool {
+		lit, ok := node.(*goast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return true
+		}
+		input, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 && len(program.Statements) > 0 {
+			checkRoundTrip(t, input, program)
+			checked++
+		}
+		return true
+	})
+	if checked == 0 {
+		t.Fatalf("no inputs were checked")
+	}
+}
+
+// checkRoundTrip checks that parsing program.String() yields the same tree as program
+func checkRoundTrip(t *testing.T, input string, program *ast.Program) {
+	t.Helper()
+	source := program.String()
+	p := New(lexer.New(source))
+	reparsed := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("String() of %q is not valid source.\nsource = %q\nerrors = %v", input, source, p.Errors())
+	}
+	if !ast.Equal(program, reparsed) {
+		t.Fatalf("String() of %q parses into a different tree.\nsource = %q\ngot    = %q", input, source, reparsed.String())
+	}
+}
+
+// optionalFields lists the node fields that may be nil in a parsed tree
+var optionalFields = map[string]bool{
+	"IfExpression.Alternative": true,
+	"ForStatement.Init":        true,
+	"ForStatement.Condition":   true,
+	"ForStatement.Post":        true,
+	"FunctionLiteral.Rest":     true,
+	"MatchArm.Guard":           true,
+	"ArrayPattern.Rest":        true,
+	"LetStatement.Name":        true, // exactly one of Name and Pattern is set, which findNilChild checks
+	"LetStatement.Pattern":     true,
+	"FunctionLiteral.Defaults": true, // elements are nil for parameters without a default
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// findNilChild describes the first required child of node that is nil, or returns "" if there is none
+func findNilChild(node ast.Node, path string) string {
+	v := reflect.ValueOf(node)
+	if node == nil || v.IsNil() {
+		return path + " is nil"
+	}
+	if ls, ok := node.(*ast.LetStatement); ok && (ls.Name == nil) == (ls.Pattern == nil) {
+		return path + " needs exactly one of Name and Pattern"
+	}
+	if fl, ok := node.(*ast.FunctionLiteral); ok && fl.Defaults != nil && len(fl.Defaults) != len(fl.Parameters) {
+		return path + ".Defaults is not aligned with Parameters"
+	}
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Field(i)
+		name := elem.Type().Name() + "." + elem.Type().Field(i).Name
+		switch {
+		case field.Type().Implements(nodeType):
+			if field.IsNil() {
+				if optionalFields[name] {
+					continue
+				}
+				return path + "." + name + " is nil"
+			}
+			if msg := findNilChild(field.Interface().(ast.Node), path+"."+name); msg != "" {
+				return msg
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			for j := 0; j < field.Len(); j++ {
+				child := field.Index(j)
+				if child.IsNil() {
+					if optionalFields[name] {
+						continue
+					}
+					return fmt.Sprintf("%s.%s[%d] is nil", path, name, j)
+				}
+				if msg := findNilChild(child.Interface().(ast.Node), fmt.Sprintf("%s.%s[%d]", path, name, j)); msg != "" {
+					return msg
+				}
+			}
+		}
+	}
+	return ""
+}
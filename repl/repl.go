@@ -7,12 +7,14 @@ import (
 	"io"
 
 	"github.com/BentleyOph/monke/lexer"
+	"github.com/BentleyOph/monke/macro"
 )
 
 const PROMPT = ">>"
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in) // initialize scanner to read from input
+	macros := macro.Macros{}        // macros defined so far, usable on later lines
 	for {
 		fmt.Printf(PROMPT)
 		scanned := scanner.Scan() // scan the input
@@ -28,7 +30,15 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, p.Errors())
 			continue
 		}
-		io.WriteString(out, program.String())
+		for name, m := range macro.DefineMacros(program) {
+			macros[name] = m
+		}
+		expanded, errors := macro.ExpandMacros(program, macros)
+		if len(errors) != 0 {
+			printMacroErrors(out, errors)
+			continue
+		}
+		io.WriteString(out, expanded.String())
 		io.WriteString(out, "\n")
 
 	}
@@ -57,3 +67,11 @@ func printParserErrors(out io.Writer, errors []string) {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+func printMacroErrors(out io.Writer, errors []string) {
+	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
+	io.WriteString(out, " macro errors:\n")
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
+	}
+}
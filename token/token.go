@@ -5,6 +5,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     int // byte offset of the first character of the token in the input
+	End     int // byte offset just past the last character of the token
 }
 
 // token types
@@ -22,21 +24,40 @@ const (
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
+	POWER    = "**"
 	SLASH    = "/"
 	LT       = "<"
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
-	
+	NULLISH  = "??"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	//Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ARROW     = "=>"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
+	ELLIPSIS = "..."
+	DOT      = "."
+
+	// optional access: a?.b and a?[i]
+	OPTIONAL_DOT      = "?."
+	OPTIONAL_LBRACKET = "?["
+
 	//Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -45,19 +66,40 @@ const (
 	RETURN   = "RETURN"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	INFIX    = "INFIX"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MACRO    = "MACRO"
+	NULL     = "NULL"
+	MATCH    = "MATCH"
+	STRUCT   = "STRUCT"
 
 	//String
 	STRING = "STRING"
+
+	// DOC is a /// doc comment; its Literal is the text after the slashes
+	DOC = "DOC"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"infix":    INFIX,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"macro":    MACRO,
+	"null":     NULL,
+	"match":    MATCH,
+	"struct":   STRUCT,
 }
 
 func LookupIdent(ident string) TokenType {